@@ -3,34 +3,212 @@ package config
 import (
 	"fmt"
 	"log"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Database DatabaseConfig
-	BaseURL  string
-	DataDir  string
+	Server   ServerConfig   `json:"server"`
+	Log      LogConfig      `json:"log"`
+	Tracing  TracingConfig  `json:"tracing"`
+	Database DatabaseConfig `json:"database"`
+	GeoIP    GeoIPConfig    `json:"geoip"`
+	Features FeaturesConfig `json:"features"`
+	BaseURL  string         `json:"base_url"`
+	DataDir  string         `json:"data_dir"`
+	Trash    TrashConfig    `json:"trash"`
+	Expiry   ExpiryConfig   `json:"expiry"`
+	Unlock   UnlockConfig   `json:"unlock"`
+	// PrelaunchURL is the default destination for scheduled links before they go live
+	PrelaunchURL string `json:"prelaunch_url"`
+	// TemplateDir holds workspace templates that replace the built-in pages
+	TemplateDir string         `json:"template_dir"`
+	Redirect    RedirectConfig `json:"redirect"`
+	// BatchMaxItems caps the number of links in one batch shorten request
+	BatchMaxItems int `json:"batch_max_items"`
+	// ClickQueueSize caps the number of clicks waiting to be recorded;
+	// further clicks are dropped
+	ClickQueueSize int               `json:"click_queue_size"`
+	Canonical      CanonicalConfig   `json:"canonical"`
+	Destination    DestinationConfig `json:"destination"`
+	Risk           RiskConfig        `json:"risk"`
+	Reputation     ReputationConfig  `json:"reputation"`
+	Report         ReportConfig      `json:"report"`
+	RateLimit      RateLimitConfig   `json:"rate_limit"`
+	// AdminToken guards the admin endpoints; empty disables them
+	AdminToken string `json:"admin_token" secret:"true"`
+}
+
+// ServerConfig holds the HTTP server settings. Zero timeouts mean none.
+type ServerConfig struct {
+	Addr              string        `json:"addr"`
+	ReadTimeout       time.Duration `json:"read_timeout"`
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout"`
+	WriteTimeout      time.Duration `json:"write_timeout"`
+	IdleTimeout       time.Duration `json:"idle_timeout"`
+	// ShutdownTimeout is how long requests in flight may take to finish
+	// when the server stops
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+	// DrainDelay is how long /readyz reports not ready before the server
+	// stops, so that load balancers stop sending it requests
+	DrainDelay time.Duration `json:"drain_delay"`
+	// StaticDir holds the web UI
+	StaticDir string `json:"static_dir"`
+	// TrustedProxies lists the addresses and CIDR ranges of the proxies whose
+	// X-Forwarded-For and X-Real-IP headers give the client address. Empty
+	// means none, so the client is whoever opened the connection.
+	TrustedProxies []string `json:"trusted_proxies"`
+	// AdminAddr is where the admin listener serves pprof, expvar, the log
+	// level and the admin API: a loopback host:port or unix:/path/to.sock.
+	// Empty disables it.
+	AdminAddr string `json:"admin_addr"`
+}
+
+// LogConfig holds the logging settings
+type LogConfig struct {
+	// Level is debug, info, warn or error
+	Level string `json:"level"`
+	// Format is console or json
+	Format string `json:"format"`
+}
+
+// TracingConfig holds the OpenTelemetry tracing settings
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp
+	Exporter string `json:"exporter"`
+	// Endpoint is the OTLP/HTTP URL spans are sent to; empty means the
+	// standard OTEL_EXPORTER_OTLP_* variables
+	Endpoint    string  `json:"endpoint" secret:"url"`
+	ServiceName string  `json:"service_name"`
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// GeoIPConfig holds the paths of the GeoIP databases
+type GeoIPConfig struct {
+	CityDB string `json:"city_db"`
+}
+
+// FeaturesConfig switches optional features on and off
+type FeaturesConfig struct {
+	Analytics bool `json:"analytics"`
+	QRCodes   bool `json:"qr_codes"`
+	Reports   bool `json:"reports"`
+	GeoIP     bool `json:"geoip"`
+	Metrics   bool `json:"metrics"`
+}
+
+// RateLimitConfig limits requests per client IP address. Clients may make
+// Burst requests at once and Requests per Period on average; zero Requests
+// disables the limit. PolicyFile optionally sets limits per route and API
+// key, and monthly link quotas, falling back to this limit.
+type RateLimitConfig struct {
+	Requests   int           `json:"requests"`
+	Period     time.Duration `json:"period"`
+	Burst      int           `json:"burst"`
+	PolicyFile string        `json:"policy_file"`
+}
+
+// ReportConfig limits abuse reports per IP address
+type ReportConfig struct {
+	MaxPerIP int           `json:"max_per_ip"`
+	Window   time.Duration `json:"window"`
+}
+
+// ReputationConfig holds the external reputation checks. They are disabled
+// unless SafeBrowsingKey is set.
+type ReputationConfig struct {
+	SafeBrowsingKey      string        `json:"safe_browsing_key" secret:"true"`
+	SafeBrowsingEndpoint string        `json:"safe_browsing_endpoint"`
+	CacheTTL             time.Duration `json:"cache_ttl"`
+	// RescanInterval is how often existing links are rescanned; zero disables it
+	RescanInterval time.Duration `json:"rescan_interval"`
+}
+
+// RiskConfig holds the phishing checks run on new destinations
+type RiskConfig struct {
+	// BlocklistFile is a file of blocked domains and patterns; empty disables it
+	BlocklistFile   string        `json:"blocklist_file"`
+	BlocklistReload time.Duration `json:"blocklist_reload"`
+	MaxSubdomains   int           `json:"max_subdomains"`
+	// Destinations scoring at least HoldScore are held for moderation and
+	// those scoring at least RejectScore are refused
+	HoldScore   int `json:"hold_score"`
+	RejectScore int `json:"reject_score"`
+}
+
+// DestinationConfig holds the policy for where links may point. Empty lists
+// keep the built-in defaults.
+type DestinationConfig struct {
+	AllowedSchemes  []string       `json:"allowed_schemes"`
+	BlockedHosts    []string       `json:"blocked_hosts"`
+	BlockedNetworks []netip.Prefix `json:"blocked_networks"`
+	MaxLength       int            `json:"max_length"`
+	// OwnHosts are the hosts of this service, starting with the BASE_URL host
+	OwnHosts     []string `json:"own_hosts"`
+	ResolveHosts bool     `json:"resolve_hosts"`
+}
+
+// CanonicalConfig holds the optional URL canonicalization steps
+type CanonicalConfig struct {
+	SortQuery     bool `json:"sort_query"`
+	StripTracking bool `json:"strip_tracking"`
+	// TrackingParams replaces the built-in list of tracking parameters when set
+	TrackingParams []string `json:"tracking_params"`
+}
+
+// RedirectConfig holds the server default redirect profile
+type RedirectConfig struct {
+	StatusCode     int    `json:"status_code"`
+	CacheMaxAge    int    `json:"cache_max_age"`
+	ReferrerPolicy string `json:"referrer_policy"`
+	NoIndex        bool   `json:"noindex"`
+}
+
+// UnlockConfig holds the settings for password-protected links
+type UnlockConfig struct {
+	Secret        string        `json:"secret" secret:"true"`
+	CookieTTL     time.Duration `json:"cookie_ttl"`
+	MaxFailures   int           `json:"max_failures"`
+	FailureWindow time.Duration `json:"failure_window"`
+}
+
+// ExpiryConfig holds the server-wide link expiry settings. A zero DefaultTTL
+// means links never expire unless asked to, and a zero MaxTTL means no limit.
+type ExpiryConfig struct {
+	DefaultTTL time.Duration `json:"default_ttl"`
+	MaxTTL     time.Duration `json:"max_ttl"`
+}
+
+// TrashConfig controls how long deleted links are kept before being purged
+type TrashConfig struct {
+	GracePeriod   time.Duration `json:"grace_period"`
+	PurgeInterval time.Duration `json:"purge_interval"`
 }
 
 // DatabaseConfig represents database configuration
 type DatabaseConfig struct {
-	SQLite SQLiteConfig
-	Redis  RedisConfig
+	SQLite SQLiteConfig `json:"sqlite"`
+	Redis  RedisConfig  `json:"redis"`
 }
 
 // SQLiteConfig represents SQLite configuration
 type SQLiteConfig struct {
-	Path string
+	Path string `json:"path"`
 }
 
-// RedisConfig represents Redis configuration
+// RedisConfig represents Redis configuration. An empty URL disables Redis.
 type RedisConfig struct {
-	URL      string
-	Password string
-	DB       int
+	URL      string `json:"url" secret:"url"`
+	Password string `json:"password" secret:"true"`
+	DB       int    `json:"db"`
 }
 
 // GetDSN returns the SQLite connection string
@@ -40,66 +218,306 @@ func (c SQLiteConfig) GetDSN() string {
 
 // GetRedisURL returns the Redis URL
 func (c RedisConfig) GetRedisURL() string {
-	log.Printf("Redis URL from config: %s", c.URL)
-	
+	log.Printf("Redis URL from config: %s", RedactURL(c.URL))
+
 	// Validate Redis URL
 	if c.URL == "" {
 		log.Printf("Warning: Redis URL is empty")
 		return "redis://localhost:6379/0"
 	}
-	
+
 	if !strings.HasPrefix(c.URL, "redis://") && !strings.HasPrefix(c.URL, "rediss://") {
-		log.Printf("Warning: Redis URL does not have valid scheme: %s", c.URL)
+		log.Printf("Warning: Redis URL does not have valid scheme: %s", RedactURL(c.URL))
 		return fmt.Sprintf("redis://%s", c.URL)
 	}
-	
+
 	return c.URL
 }
 
-// Load loads the configuration from environment variables
+// Load loads the configuration from the file named by CONFIG_FILE, if any,
+// with environment variables taking precedence over the file. It reports
+// every invalid setting at once.
 func Load() (*Config, error) {
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile loads the configuration from a YAML or TOML file, or only from
+// the environment when path is empty. Environment variables take precedence
+// over the file.
+func LoadFile(path string) (*Config, error) {
+	l, err := newLoader(path)
+	if err != nil {
+		return nil, err
+	}
+
 	// Ensure data directory exists
-	dataDir := getEnv("DATA_DIR", "./data")
+	dataDir := l.get("DATA_DIR", "./data")
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create data directory: %v", err)
+		l.errorf("failed to create data directory: %v", err)
 	}
 
-	redisURL := getEnv("REDIS_URL", "redis://redis:6379/0")
-	log.Printf("Loading Redis URL from environment: %s", redisURL)
+	cfg := &Config{
+		Server:   loadServerConfig(l),
+		Log:      loadLogConfig(l),
+		Tracing:  loadTracingConfig(l),
+		Database: loadDatabaseConfig(l, dataDir),
+		GeoIP: GeoIPConfig{
+			CityDB: l.get("GEOIP_CITY_DB", filepath.Join(dataDir, "geoip", "GeoLite2-City.mmdb")),
+		},
+		Features: FeaturesConfig{
+			Analytics: l.getBool("FEATURES_ANALYTICS", true),
+			QRCodes:   l.getBool("FEATURES_QR_CODES", true),
+			Reports:   l.getBool("FEATURES_REPORTS", true),
+			GeoIP:     l.getBool("FEATURES_GEOIP", true),
+			Metrics:   l.getBool("FEATURES_METRICS", true),
+		},
+		BaseURL:      l.get("BASE_URL", "http://localhost:8080"),
+		DataDir:      dataDir,
+		PrelaunchURL: l.get("PRELAUNCH_URL", ""),
+		TemplateDir:  l.get("TEMPLATE_DIR", ""),
+		Trash: TrashConfig{
+			GracePeriod:   l.getDuration("TRASH_GRACE_PERIOD", 30*24*time.Hour),
+			PurgeInterval: l.getDuration("TRASH_PURGE_INTERVAL", time.Hour),
+		},
+		Expiry: ExpiryConfig{
+			DefaultTTL: l.getDuration("LINK_DEFAULT_TTL", 30*24*time.Hour),
+			MaxTTL:     l.getDuration("LINK_MAX_TTL", 0),
+		},
+		Unlock: UnlockConfig{
+			Secret:        l.getSecret("UNLOCK_SECRET"),
+			CookieTTL:     l.getDuration("UNLOCK_COOKIE_TTL", 15*time.Minute),
+			MaxFailures:   l.getInt("UNLOCK_MAX_FAILURES", 5),
+			FailureWindow: l.getDuration("UNLOCK_FAILURE_WINDOW", 15*time.Minute),
+		},
+		Redirect: RedirectConfig{
+			StatusCode:     l.getInt("REDIRECT_STATUS_CODE", 302),
+			CacheMaxAge:    l.getInt("REDIRECT_CACHE_MAX_AGE", 0),
+			ReferrerPolicy: l.get("REDIRECT_REFERRER_POLICY", ""),
+			NoIndex:        l.getBool("REDIRECT_NOINDEX", false),
+		},
+		BatchMaxItems:  l.getInt("SHORTEN_BATCH_MAX_ITEMS", 500),
+		ClickQueueSize: l.getInt("CLICK_QUEUE_SIZE", 1000),
+		Canonical: CanonicalConfig{
+			SortQuery:      l.getBool("CANONICAL_SORT_QUERY", false),
+			StripTracking:  l.getBool("CANONICAL_STRIP_TRACKING", false),
+			TrackingParams: l.getList("CANONICAL_TRACKING_PARAMS"),
+		},
+		Risk: loadRiskConfig(l),
+		Reputation: ReputationConfig{
+			SafeBrowsingKey:      l.getSecret("SAFE_BROWSING_API_KEY"),
+			SafeBrowsingEndpoint: l.get("SAFE_BROWSING_ENDPOINT", ""),
+			CacheTTL:             l.getDuration("REPUTATION_CACHE_TTL", 30*time.Minute),
+			RescanInterval:       l.getDuration("REPUTATION_RESCAN_INTERVAL", 24*time.Hour),
+		},
+		Report: ReportConfig{
+			MaxPerIP: l.getInt("REPORT_RATE_LIMIT", 5),
+			Window:   l.getDuration("REPORT_RATE_WINDOW", time.Hour),
+		},
+		RateLimit:  loadRateLimitConfig(l),
+		AdminToken: l.getSecret("ADMIN_TOKEN"),
+	}
+	cfg.Destination = loadDestinationConfig(l, cfg.BaseURL)
 
-	// Validate Redis URL
-	if redisURL == "" {
-		log.Printf("Warning: Redis URL is empty, Redis features will be disabled")
-		redisURL = "redis://localhost:6379/0"
-	}
-
-	if !strings.HasPrefix(redisURL, "redis://") && !strings.HasPrefix(redisURL, "rediss://") {
-		redisURL = fmt.Sprintf("redis://%s", redisURL)
-		log.Printf("Added redis:// scheme to URL: %s", redisURL)
-	}
-
-	return &Config{
-		Database: DatabaseConfig{
-			SQLite: SQLiteConfig{
-				Path: filepath.Join(dataDir, "urlshortener.db"),
-			},
-			Redis: RedisConfig{
-				URL:      redisURL,
-				Password: getEnv("REDIS_PASSWORD", ""),
-				DB:       0,
-			},
+	if cfg.Expiry.MaxTTL > 0 && cfg.Expiry.DefaultTTL > cfg.Expiry.MaxTTL {
+		l.errorf("LINK_DEFAULT_TTL (%s) exceeds LINK_MAX_TTL (%s)", cfg.Expiry.DefaultTTL, cfg.Expiry.MaxTTL)
+	}
+	if cfg.BatchMaxItems < 1 {
+		l.errorf("SHORTEN_BATCH_MAX_ITEMS must be at least 1")
+	}
+	if cfg.ClickQueueSize < 1 {
+		l.errorf("CLICK_QUEUE_SIZE must be at least 1")
+	}
+	if cfg.Trash.PurgeInterval <= 0 || cfg.Trash.GracePeriod < 0 {
+		l.errorf("TRASH_PURGE_INTERVAL must be positive and TRASH_GRACE_PERIOD must not be negative")
+	}
+	if cfg.Reputation.CacheTTL <= 0 || cfg.Reputation.RescanInterval < 0 {
+		l.errorf("REPUTATION_CACHE_TTL must be positive and REPUTATION_RESCAN_INTERVAL must not be negative")
+	}
+	if cfg.Report.MaxPerIP < 0 || cfg.Report.Window <= 0 {
+		l.errorf("REPORT_RATE_LIMIT must not be negative and REPORT_RATE_WINDOW must be positive")
+	}
+
+	if err := l.err(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// loadServerConfig reads the HTTP server settings
+func loadServerConfig(l *loader) ServerConfig {
+	cfg := ServerConfig{
+		// PORT is kept for platforms that set it
+		Addr:              l.get("SERVER_ADDR", ":"+l.get("PORT", "8080")),
+		ReadTimeout:       l.getDuration("SERVER_READ_TIMEOUT", 15*time.Second),
+		ReadHeaderTimeout: l.getDuration("SERVER_READ_HEADER_TIMEOUT", 0),
+		WriteTimeout:      l.getDuration("SERVER_WRITE_TIMEOUT", 15*time.Second),
+		IdleTimeout:       l.getDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+		ShutdownTimeout:   l.getDuration("SERVER_SHUTDOWN_TIMEOUT", 10*time.Second),
+		DrainDelay:        l.getDuration("SERVER_DRAIN_DELAY", 0),
+		StaticDir:         l.get("SERVER_STATIC_DIR", "/app/static"),
+		TrustedProxies:    l.getList("SERVER_TRUSTED_PROXIES"),
+		AdminAddr:         l.get("SERVER_ADMIN_ADDR", ""),
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		l.errorf("invalid SERVER_ADDR %q: %v", cfg.Addr, err)
+	}
+	if cfg.ReadTimeout < 0 || cfg.ReadHeaderTimeout < 0 || cfg.WriteTimeout < 0 || cfg.IdleTimeout < 0 {
+		l.errorf("SERVER_READ_TIMEOUT, SERVER_READ_HEADER_TIMEOUT, SERVER_WRITE_TIMEOUT and SERVER_IDLE_TIMEOUT must not be negative")
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		l.errorf("SERVER_SHUTDOWN_TIMEOUT must be positive")
+	}
+	if cfg.DrainDelay < 0 {
+		l.errorf("SERVER_DRAIN_DELAY must not be negative")
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			l.errorf("invalid SERVER_TRUSTED_PROXIES entry %q: use an IP address or CIDR range", proxy)
+		}
+	}
+	if cfg.AdminAddr != "" && !strings.HasPrefix(cfg.AdminAddr, "unix:") && !isLoopbackAddr(cfg.AdminAddr) {
+		l.errorf("invalid SERVER_ADMIN_ADDR %q: use a localhost or loopback address, or unix:/path/to.sock", cfg.AdminAddr)
+	}
+	return cfg
+}
+
+// isLoopbackAddr reports whether addr is a host:port only reachable from
+// this machine
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// loadLogConfig reads the logging settings
+func loadLogConfig(l *loader) LogConfig {
+	cfg := LogConfig{
+		Level:  strings.ToLower(l.get("LOG_LEVEL", "debug")),
+		Format: strings.ToLower(l.get("LOG_FORMAT", "console")),
+	}
+	if _, err := zapcore.ParseLevel(cfg.Level); err != nil {
+		l.errorf("invalid LOG_LEVEL %q: use debug, info, warn or error", cfg.Level)
+	}
+	if cfg.Format != "console" && cfg.Format != "json" {
+		l.errorf("invalid LOG_FORMAT %q: use console or json", cfg.Format)
+	}
+	return cfg
+}
+
+// loadTracingConfig reads the tracing settings
+func loadTracingConfig(l *loader) TracingConfig {
+	cfg := TracingConfig{
+		Exporter:    strings.ToLower(l.get("TRACING_EXPORTER", "none")),
+		Endpoint:    l.getURL("TRACING_ENDPOINT", ""),
+		ServiceName: l.get("TRACING_SERVICE_NAME", "urlshortener"),
+		SampleRatio: l.getFloat("TRACING_SAMPLE_RATIO", 1),
+	}
+	if cfg.Exporter != "none" && cfg.Exporter != "stdout" && cfg.Exporter != "otlp" {
+		l.errorf("invalid TRACING_EXPORTER %q: use none, stdout or otlp", cfg.Exporter)
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		l.errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+	return cfg
+}
+
+// loadDatabaseConfig reads the SQLite and Redis settings
+func loadDatabaseConfig(l *loader, dataDir string) DatabaseConfig {
+	cfg := DatabaseConfig{
+		SQLite: SQLiteConfig{
+			Path: l.get("SQLITE_PATH", filepath.Join(dataDir, "urlshortener.db")),
 		},
-		BaseURL: getEnv("BASE_URL", "http://localhost:8080"),
-		DataDir: dataDir,
-	}, nil
+		Redis: RedisConfig{
+			URL:      l.getURL("REDIS_URL", ""),
+			Password: l.getSecret("REDIS_PASSWORD"),
+			DB:       l.getInt("REDIS_DB", 0),
+		},
+	}
+	if cfg.SQLite.Path == "" {
+		l.errorf("SQLITE_PATH must not be empty")
+	}
+	if u := cfg.Redis.URL; u != "" && !strings.HasPrefix(u, "redis://") && !strings.HasPrefix(u, "rediss://") {
+		cfg.Redis.URL = "redis://" + u
+		log.Printf("Added redis:// scheme to URL: %s", RedactURL(cfg.Redis.URL))
+	}
+	if cfg.Redis.DB < 0 {
+		l.errorf("REDIS_DB must not be negative")
+	}
+	return cfg
 }
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		log.Printf("Environment variable %s found: %s", key, value)
-		return value
+// loadDestinationConfig reads the destination policy settings
+func loadDestinationConfig(l *loader, baseURL string) DestinationConfig {
+	cfg := DestinationConfig{
+		AllowedSchemes: l.getList("DESTINATION_ALLOWED_SCHEMES"),
+		BlockedHosts:   l.getList("DESTINATION_BLOCKED_HOSTS"),
+		MaxLength:      l.getInt("DESTINATION_MAX_LENGTH", 2048),
+		ResolveHosts:   l.getBool("DESTINATION_RESOLVE_HOSTS", false),
+	}
+
+	for _, network := range l.getList("DESTINATION_BLOCKED_NETWORKS") {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(network)
+			if addrErr != nil {
+				l.errorf("invalid network in DESTINATION_BLOCKED_NETWORKS: %v", err)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		cfg.BlockedNetworks = append(cfg.BlockedNetworks, prefix)
+	}
+	if cfg.MaxLength < 1 {
+		l.errorf("DESTINATION_MAX_LENGTH must be at least 1")
 	}
-	log.Printf("Environment variable %s not found, using default: %s", key, defaultValue)
-	return defaultValue
-} 
\ No newline at end of file
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		l.errorf("invalid BASE_URL: %v", err)
+	} else if host := base.Hostname(); host != "" {
+		cfg.OwnHosts = append(cfg.OwnHosts, strings.ToLower(host))
+	}
+	cfg.OwnHosts = append(cfg.OwnHosts, l.getList("DESTINATION_OWN_HOSTS")...)
+	return cfg
+}
+
+// loadRiskConfig reads the blocklist and risk scoring settings
+func loadRiskConfig(l *loader) RiskConfig {
+	cfg := RiskConfig{
+		BlocklistFile:   l.get("BLOCKLIST_FILE", ""),
+		BlocklistReload: l.getDuration("BLOCKLIST_RELOAD_INTERVAL", time.Minute),
+		MaxSubdomains:   l.getInt("RISK_MAX_SUBDOMAINS", 3),
+		HoldScore:       l.getInt("RISK_HOLD_SCORE", 50),
+		RejectScore:     l.getInt("RISK_REJECT_SCORE", 80),
+	}
+	if cfg.BlocklistReload <= 0 {
+		l.errorf("BLOCKLIST_RELOAD_INTERVAL must be positive")
+	}
+	if cfg.MaxSubdomains < 1 {
+		l.errorf("RISK_MAX_SUBDOMAINS must be at least 1")
+	}
+	if cfg.HoldScore < 1 || cfg.RejectScore < cfg.HoldScore {
+		l.errorf("RISK_HOLD_SCORE (%d) must be at least 1 and at most RISK_REJECT_SCORE (%d)", cfg.HoldScore, cfg.RejectScore)
+	}
+	return cfg
+}
+
+// loadRateLimitConfig reads the request rate limit settings
+func loadRateLimitConfig(l *loader) RateLimitConfig {
+	cfg := RateLimitConfig{
+		Requests:   l.getInt("RATE_LIMIT_REQUESTS", 60),
+		Period:     l.getDuration("RATE_LIMIT_PERIOD", time.Minute),
+		Burst:      l.getInt("RATE_LIMIT_BURST", 0),
+		PolicyFile: l.get("RATE_LIMIT_POLICY_FILE", ""),
+	}
+	if cfg.Requests < 0 || cfg.Period <= 0 || cfg.Burst < 0 {
+		l.errorf("RATE_LIMIT_REQUESTS and RATE_LIMIT_BURST must not be negative and RATE_LIMIT_PERIOD must be positive")
+	}
+	return cfg
+}
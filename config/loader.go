@@ -0,0 +1,268 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// envAliases maps settings to the environment variables they were read from
+// before they were renamed
+var envAliases = map[string]string{
+	"RATE_LIMIT_REQUESTS": "RATE_LIMIT",
+}
+
+// fileValue is a setting read from the config file
+type fileValue struct {
+	// name is the setting's path in the file, e.g. server.read_timeout
+	name  string
+	value string
+}
+
+// loader reads settings from the environment, then from the config file,
+// then falls back to defaults. It collects every invalid setting so that
+// they can be reported together.
+type loader struct {
+	path string
+	// file holds the config file settings by environment variable name
+	file map[string]fileValue
+	used map[string]bool
+	errs []error
+}
+
+// newLoader creates a loader, reading the config file at path unless it is
+// empty
+func newLoader(path string) (*loader, error) {
+	l := &loader{
+		path: path,
+		file: make(map[string]fileValue),
+		used: make(map[string]bool),
+	}
+	if path == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	var settings map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &settings)
+	case ".toml":
+		err = toml.Unmarshal(data, &settings)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q, use .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	if err := flatten("", settings, l.file); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	log.Printf("Loaded config file %s", path)
+	return l, nil
+}
+
+// flatten stores the settings of a config file section under the name of
+// the matching environment variable: server.read_timeout becomes
+// SERVER_READ_TIMEOUT. Lists become comma-separated values.
+func flatten(prefix string, section map[string]interface{}, out map[string]fileValue) error {
+	for key, value := range section {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		env := strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+		env = strings.ReplaceAll(env, "-", "_")
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(name, v, out); err != nil {
+				return err
+			}
+			continue
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if _, ok := item.(map[string]interface{}); ok {
+					return fmt.Errorf("%s must be a list of values", name)
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			out[env] = fileValue{name: name, value: strings.Join(items, ",")}
+		case nil:
+			out[env] = fileValue{name: name}
+		default:
+			out[env] = fileValue{name: name, value: fmt.Sprint(v)}
+		}
+	}
+	return nil
+}
+
+// lookup returns the value of a setting from the environment or the config
+// file. The value is logged after passing it through redact, unless that
+// is nil.
+func (l *loader) lookup(key string, redact func(string) string) (string, bool) {
+	l.used[key] = true
+	source, value, ok := "environment variable", "", false
+	if value, ok = os.LookupEnv(key); !ok {
+		if alias, hasAlias := envAliases[key]; hasAlias {
+			value, ok = os.LookupEnv(alias)
+		}
+	}
+	if !ok {
+		var setting fileValue
+		if setting, ok = l.file[key]; ok {
+			source, value = "config file setting", setting.value
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	logged := value
+	if redact != nil {
+		logged = redact(value)
+	}
+	log.Printf("Setting %s found in %s: %s", key, source, logged)
+	return value, true
+}
+
+// name returns how to refer to a setting in errors
+func (l *loader) name(key string) string {
+	if _, ok := os.LookupEnv(key); !ok {
+		if setting, ok := l.file[key]; ok {
+			return setting.name
+		}
+	}
+	return key
+}
+
+// errorf records an invalid setting
+func (l *loader) errorf(format string, args ...interface{}) {
+	l.errs = append(l.errs, fmt.Errorf(format, args...))
+}
+
+// get gets a setting or returns a default value
+func (l *loader) get(key, defaultValue string) string {
+	if value, ok := l.lookup(key, nil); ok {
+		return value
+	}
+	log.Printf("Setting %s not found, using default: %s", key, defaultValue)
+	return defaultValue
+}
+
+// getURL gets a URL setting, masking any password in the logs, or returns a
+// default value
+func (l *loader) getURL(key, defaultValue string) string {
+	if value, ok := l.lookup(key, RedactURL); ok {
+		return value
+	}
+	log.Printf("Setting %s not found, using default: %s", key, RedactURL(defaultValue))
+	return defaultValue
+}
+
+// getSecret gets a secret setting, or an empty string, without logging its
+// value. The secret can instead be read from the file named by the setting
+// with a _FILE suffix, as with Docker and Kubernetes secrets: the contents
+// of UNLOCK_SECRET_FILE are used as UNLOCK_SECRET.
+func (l *loader) getSecret(key string) string {
+	value, _ := l.lookup(key, redactSecret)
+	path, fromFile := l.lookup(key+"_FILE", nil)
+	switch {
+	case value != "" && fromFile:
+		l.errorf("%s and %s are exclusive; set only one", l.name(key), l.name(key+"_FILE"))
+	case fromFile:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			l.errorf("failed to read %s: %v", l.name(key+"_FILE"), err)
+			return ""
+		}
+		// Editors and echo add a final newline that is not part of the secret
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+	return value
+}
+
+// getList gets a comma-separated setting as a list, or nil when it is unset
+// or empty
+func (l *loader) getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(l.get(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getDuration gets a setting as a time.Duration or returns a default value
+func (l *loader) getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := l.get(key, defaultValue.String())
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.errorf("invalid duration for %s: %v", l.name(key), err)
+		return defaultValue
+	}
+	return d
+}
+
+// getInt gets a setting as an int or returns a default value
+func (l *loader) getInt(key string, defaultValue int) int {
+	value := l.get(key, strconv.Itoa(defaultValue))
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		l.errorf("invalid integer for %s: %v", l.name(key), err)
+		return defaultValue
+	}
+	return n
+}
+
+// getFloat gets a setting as a float64 or returns a default value
+func (l *loader) getFloat(key string, defaultValue float64) float64 {
+	value := l.get(key, strconv.FormatFloat(defaultValue, 'g', -1, 64))
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.errorf("invalid number for %s: %v", l.name(key), err)
+		return defaultValue
+	}
+	return f
+}
+
+// getBool gets a setting as a bool or returns a default value
+func (l *loader) getBool(key string, defaultValue bool) bool {
+	value := l.get(key, strconv.FormatBool(defaultValue))
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.errorf("invalid boolean for %s: %v", l.name(key), err)
+		return defaultValue
+	}
+	return b
+}
+
+// err returns every invalid setting, including config file settings that
+// were never read, or nil
+func (l *loader) err() error {
+	var unknown []string
+	for key, setting := range l.file {
+		if !l.used[key] {
+			unknown = append(unknown, setting.name)
+		}
+	}
+	sort.Strings(unknown)
+	errs := l.errs
+	for _, name := range unknown {
+		errs = append(errs, fmt.Errorf("unknown setting %s in %s", name, l.path))
+	}
+	return errors.Join(errs...)
+}
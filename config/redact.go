@@ -0,0 +1,113 @@
+package config
+
+import (
+	"encoding"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// RedactedValue is shown in place of secret settings
+const RedactedValue = "[REDACTED]"
+
+// Redacted returns the configuration as nested maps keyed by the fields'
+// JSON names, safe to log or show to administrators. Fields tagged
+// `secret:"true"` are replaced with RedactedValue when set, and the
+// passwords of fields tagged `secret:"url"` are masked. Durations are
+// written as strings such as "1m0s".
+func (c *Config) Redacted() map[string]interface{} {
+	return settingsMap(reflect.ValueOf(*c), true)
+}
+
+// RedactURL masks the password in a URL, e.g. redis://:pass@host. Values
+// that do not parse are replaced entirely.
+func RedactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return RedactedValue
+	}
+	return u.Redacted()
+}
+
+// redactSecret returns how a secret setting is shown
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return RedactedValue
+}
+
+var (
+	durationType      = reflect.TypeOf(time.Duration(0))
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// settingsMap converts a config struct to a map, redacting secret fields if
+// asked to
+func settingsMap(v reflect.Value, redact bool) map[string]interface{} {
+	out := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, ok := settingName(field)
+		if !ok {
+			continue
+		}
+
+		switch secret := field.Tag.Get("secret"); {
+		case redact && secret == "true":
+			out[name] = redactSecret(v.Field(i).String())
+		case redact && secret == "url":
+			out[name] = RedactURL(v.Field(i).String())
+		default:
+			out[name] = settingValue(v.Field(i), redact)
+		}
+	}
+	return out
+}
+
+// settingName returns the JSON name of a config field, and false for
+// fields that are not settings
+func settingName(field reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if !field.IsExported() || name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// settingValue converts a setting to a value that encodes readably as JSON
+func settingValue(v reflect.Value, redact bool) interface{} {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Type().Implements(textMarshalerType):
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return RedactedValue
+		}
+		return string(text)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return settingsMap(v, redact)
+	case reflect.Slice:
+		if v.IsNil() {
+			return []interface{}{}
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = settingValue(v.Index(i), redact)
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Change is a setting that differs between two configurations. Secret
+// values are redacted.
+type Change struct {
+	// Setting is the setting's path in GET /admin/config, e.g. log.level
+	Setting string      `json:"setting"`
+	Old     interface{} `json:"old"`
+	New     interface{} `json:"new"`
+	// Applied is false for settings that only take effect after a restart
+	Applied bool `json:"applied"`
+}
+
+// Diff returns the settings that differ between old and new, by name
+func Diff(old, new *Config) []Change {
+	oldRaw, newRaw := flatSettings(old, false), flatSettings(new, false)
+	oldShown, newShown := flatSettings(old, true), flatSettings(new, true)
+
+	var changes []Change
+	for setting, value := range newRaw {
+		if !reflect.DeepEqual(oldRaw[setting], value) {
+			changes = append(changes, Change{
+				Setting: setting,
+				Old:     oldShown[setting],
+				New:     newShown[setting],
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Setting < changes[j].Setting })
+	return changes
+}
+
+// flatSettings returns the settings of cfg by their dotted path
+func flatSettings(cfg *Config, redact bool) map[string]interface{} {
+	out := make(map[string]interface{})
+	var walk func(prefix string, section map[string]interface{})
+	walk = func(prefix string, section map[string]interface{}) {
+		for name, value := range section {
+			if sub, ok := value.(map[string]interface{}); ok {
+				walk(prefix+name+".", sub)
+				continue
+			}
+			out[prefix+name] = value
+		}
+	}
+	walk("", settingsMap(reflect.ValueOf(*cfg), redact))
+	return out
+}
+
+// copySetting copies the setting at the dotted path from src to dst
+func copySetting(dst, src *Config, setting string) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for _, part := range strings.Split(setting, ".") {
+		found := false
+		for i := 0; i < d.NumField(); i++ {
+			if name, ok := settingName(d.Type().Field(i)); ok && name == part {
+				d, s = d.Field(i), s.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+	}
+	d.Set(s)
+}
+
+// Reloader prepares a part of the service for a new configuration, e.g. by
+// reading the files it names. It returns a function that switches that part
+// over, or an error to keep the current configuration everywhere.
+type Reloader func(cfg *Config) (apply func(), err error)
+
+// Store holds the running configuration and reloads it from the
+// environment and the config file
+type Store struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	// mu serializes reloads
+	mu         sync.Mutex
+	reloaders  []Reloader
+	reloadable []string
+}
+
+// NewStore loads the configuration from the environment and, unless path
+// is empty, the config file at path
+func NewStore(path string) (*Store, error) {
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path}
+	s.current.Store(cfg)
+	return s, nil
+}
+
+// Config returns the running configuration. It must not be modified.
+func (s *Store) Config() *Config {
+	return s.current.Load()
+}
+
+// OnReload registers reload to be called on every reload, and makes the
+// given settings reloadable. Settings are dotted paths such as log.level,
+// or whole sections such as rate_limit.
+func (s *Store) OnReload(reload Reloader, settings ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloaders = append(s.reloaders, reload)
+	s.reloadable = append(s.reloadable, settings...)
+}
+
+// Reload loads the configuration again and switches to its reloadable
+// settings at once. Other changed settings are reported but keep their
+// current values until a restart. When the new configuration is invalid,
+// or a Reloader fails, the current configuration is kept.
+func (s *Store) Reload() ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loaded, err := LoadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	current := s.current.Load()
+	next := *current
+	changes := Diff(current, loaded)
+	for i := range changes {
+		if s.isReloadable(changes[i].Setting) {
+			copySetting(&next, loaded, changes[i].Setting)
+			changes[i].Applied = true
+		}
+	}
+
+	applies := make([]func(), 0, len(s.reloaders))
+	for _, reload := range s.reloaders {
+		apply, err := reload(&next)
+		if err != nil {
+			return nil, err
+		}
+		applies = append(applies, apply)
+	}
+	for _, apply := range applies {
+		apply()
+	}
+	s.current.Store(&next)
+
+	for _, change := range changes {
+		if change.Applied {
+			log.Printf("Setting %s changed from %v to %v", change.Setting, change.Old, change.New)
+		} else {
+			log.Printf("Setting %s changed from %v to %v; restart to apply", change.Setting, change.Old, change.New)
+		}
+	}
+	return changes, nil
+}
+
+// isReloadable reports whether a setting can change without a restart
+func (s *Store) isReloadable(setting string) bool {
+	for _, prefix := range s.reloadable {
+		if setting == prefix || strings.HasPrefix(setting, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
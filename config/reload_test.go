@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreReload(t *testing.T) {
+	dataDir := t.TempDir()
+	path := writeConfig(t, "config.yaml", `
+data_dir: `+dataDir+`
+server: {addr: ":8080"}
+log: {level: info}
+rate_limit: {requests: 60}
+unlock: {secret: old-secret}
+`)
+	store, err := NewStore(path)
+	require.NoError(t, err)
+
+	var level string
+	var failReload bool
+	store.OnReload(func(cfg *Config) (func(), error) {
+		if failReload {
+			return nil, errors.New("policy file is invalid")
+		}
+		return func() { level = cfg.Log.Level }, nil
+	}, "log.level", "rate_limit")
+
+	rewrite := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte("data_dir: "+dataDir+"\n"+content), 0644))
+	}
+	rewrite(`
+server: {addr: ":9090"}
+log: {level: warn}
+rate_limit: {requests: 100}
+unlock: {secret: new-secret}
+`)
+	changes, err := store.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Setting: "log.level", Old: "info", New: "warn", Applied: true},
+		{Setting: "rate_limit.requests", Old: 60, New: 100, Applied: true},
+		{Setting: "server.addr", Old: ":8080", New: ":9090"},
+		{Setting: "unlock.secret", Old: RedactedValue, New: RedactedValue},
+	}, changes)
+	assert.Equal(t, "warn", level)
+
+	// Settings that need a restart keep their values
+	cfg := store.Config()
+	assert.Equal(t, "warn", cfg.Log.Level)
+	assert.Equal(t, 100, cfg.RateLimit.Requests)
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.Equal(t, "old-secret", cfg.Unlock.Secret)
+
+	// Invalid configurations and failed reloaders keep the current one
+	rewrite("log: {level: loud}\n")
+	_, err = store.Reload()
+	assert.Error(t, err)
+	failReload = true
+	rewrite("log: {level: error}\n")
+	_, err = store.Reload()
+	assert.Error(t, err)
+	assert.Same(t, cfg, store.Config())
+	assert.Equal(t, "warn", level)
+}
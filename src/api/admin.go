@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/urlshortener/src/api/handlers"
+	"github.com/yourusername/urlshortener/src/logger"
+)
+
+// unixPrefix marks admin addresses that are Unix socket paths
+const unixPrefix = "unix:"
+
+// AdminServer serves profiling, runtime variables, the log level and the
+// admin API on a listener only reachable from this machine. Every endpoint
+// still asks for the admin token, since any local user may reach a loopback
+// port, and profiles and the command line can leak secrets.
+type AdminServer struct {
+	router   *gin.Engine
+	addr     string
+	features Features
+	server   *http.Server
+}
+
+// NewAdminServer creates an admin server listening on addr, a loopback
+// host:port or unix:/path/to.sock. It serves nothing until RegisterRoutes.
+func NewAdminServer(addr string, features Features) *AdminServer {
+	router := gin.New()
+	_ = router.SetTrustedProxies(nil)
+	router.Use(RequestID(), AccessLog(), Recovery())
+
+	return &AdminServer{
+		router:   router,
+		addr:     addr,
+		features: features,
+	}
+}
+
+// servePprof serves the pprof index and profiles
+func servePprof(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/debug/pprof/") {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
+
+// RegisterRoutes registers the debugging endpoints and the admin API under
+// /admin, all behind the admin token
+func (s *AdminServer) RegisterRoutes(urlHandler *handlers.URLHandler) {
+	routes := s.router.Group("", urlHandler.RequireAdmin)
+
+	// Profiling, as served by net/http/pprof
+	routes.GET("/debug/pprof/*profile", gin.WrapF(servePprof))
+	routes.POST("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
+
+	// Runtime variables, as served by expvar
+	routes.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+
+	// The minimum log level
+	routes.GET("/log/level", gin.WrapH(logger.LevelHandler()))
+	routes.PUT("/log/level", gin.WrapH(logger.LevelHandler()))
+
+	registerAdminRoutes(routes.Group("/admin"), urlHandler, s.features)
+}
+
+// Start listens on the admin address and serves until Shutdown. A stale
+// socket left by an earlier run is replaced.
+func (s *AdminServer) Start() error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+	s.server = &http.Server{Handler: s.router}
+
+	logger.LogInfo("Admin server starting on "+s.addr, nil)
+	return s.server.Serve(listener)
+}
+
+// listen opens the admin listener
+func (s *AdminServer) listen() (net.Listener, error) {
+	path, ok := strings.CutPrefix(s.addr, unixPrefix)
+	if !ok {
+		return net.Listen("tcp", s.addr)
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("admin socket %s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Bind inside a private directory and only move the socket into place
+	// once it is restricted to the user running the service, so nobody else
+	// can connect in between
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".admin-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	bound := filepath.Join(dir, "admin.sock")
+	listener, err := net.Listen("unix", bound)
+	if err != nil {
+		return nil, err
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(bound, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(bound, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &unixListener{Listener: listener, path: path}, nil
+}
+
+// unixListener removes its socket when closed
+type unixListener struct {
+	net.Listener
+	path string
+}
+
+// Close closes the listener and removes the socket
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	if removeErr := os.Remove(l.path); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) && err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// Shutdown gracefully shuts down the admin server
+func (s *AdminServer) Shutdown(ctx context.Context) error {
+	if s.server != nil {
+		return s.server.Shutdown(ctx)
+	}
+	return nil
+}
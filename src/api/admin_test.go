@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/urlshortener/src/api/handlers"
+	"github.com/yourusername/urlshortener/src/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAdminServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	require.NoError(t, logger.Configure("json", "info"))
+	t.Cleanup(func() { _ = logger.SetLevel("debug") })
+
+	admin := NewAdminServer("127.0.0.1:0", Features{})
+	admin.RegisterRoutes(handlers.NewURLHandler(nil, handlers.URLHandlerConfig{
+		AdminToken: "secret",
+		Settings:   func() interface{} { return gin.H{"base_url": "http://sho.rt"} },
+	}))
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		admin.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Every endpoint needs the admin token
+	for _, path := range []string{"/debug/pprof/cmdline", "/debug/vars", "/log/level", "/admin/config"} {
+		w := httptest.NewRecorder()
+		admin.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+	w := httptest.NewRecorder()
+	admin.router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"debug"}`)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.True(t, logger.Get().Core().Enabled(zapcore.InfoLevel))
+
+	w = request(http.MethodGet, "/debug/pprof/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine")
+	w = request(http.MethodGet, "/debug/pprof/goroutine?debug=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine profile")
+
+	w = request(http.MethodGet, "/debug/vars", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"memstats"`)
+
+	// The log level can be read and changed
+	w = request(http.MethodGet, "/log/level", "")
+	assert.JSONEq(t, `{"level":"info"}`, w.Body.String())
+	w = request(http.MethodPut, "/log/level", `{"level":"warn"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, logger.Get().Core().Enabled(zapcore.InfoLevel))
+
+	w = request(http.MethodGet, "/admin/config", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"base_url":"http://sho.rt"}`, w.Body.String())
+}
+
+func TestAdminServerUnixSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "admin.sock")
+
+	// Stale sockets are replaced but other files are left alone
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	admin := NewAdminServer(unixPrefix+path, Features{})
+	admin.RegisterRoutes(handlers.NewURLHandler(nil, handlers.URLHandlerConfig{AdminToken: "secret"}))
+	errs := make(chan error, 1)
+	go func() { errs <- admin.Start() }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	require.Eventually(t, func() bool {
+		req, _ := http.NewRequest(http.MethodGet, "http://admin/debug/vars", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := client.Do(req)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 10*time.Millisecond)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	// The private directory the socket was bound in is gone
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, admin.Shutdown(context.Background()))
+	assert.ErrorIs(t, <-errs, http.ErrServerClosed)
+	assert.NoFileExists(t, path)
+
+	file := filepath.Join(t.TempDir(), "not-a-socket")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+	assert.ErrorContains(t, NewAdminServer(unixPrefix+file, Features{}).Start(), "is not a socket")
+}
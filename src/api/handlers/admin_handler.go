@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Config handles requests for the running configuration, with secrets
+// redacted
+func (h *URLHandler) Config(c *gin.Context) {
+	if h.settings == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "configuration is not available"})
+		return
+	}
+	c.JSON(http.StatusOK, h.settings())
+}
+
+// ReloadConfig handles requests to reload the configuration. It responds
+// with the settings that changed; when the new configuration is invalid the
+// current one is kept.
+func (h *URLHandler) ReloadConfig(c *gin.Context) {
+	if h.reload == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "configuration reload is not available"})
+		return
+	}
+	changes, err := h.reload()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
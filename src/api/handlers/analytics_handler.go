@@ -30,14 +30,14 @@ func (h *AnalyticsHandler) GetAnalytics(c *gin.Context) {
 	}
 
 	// Get URL record to verify it exists and get its ID
-	url, err := h.urlService.GetURLByShortID(shortID)
+	url, err := h.urlService.WithContext(c.Request.Context()).GetURLByShortID(shortID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found or expired"})
 		return
 	}
 
 	// Get analytics data
-	analytics, err := h.analyticsService.GetAnalytics(url.ID)
+	analytics, err := h.analyticsService.WithContext(c.Request.Context()).GetAnalytics(url.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -55,7 +55,7 @@ func (h *AnalyticsHandler) RecordClick(c *gin.Context) {
 	}
 
 	// Get URL record to verify it exists and get its ID
-	url, err := h.urlService.GetURLByShortID(shortID)
+	url, err := h.urlService.WithContext(c.Request.Context()).GetURLByShortID(shortID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found or expired"})
 		return
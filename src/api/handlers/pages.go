@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pages holds the HTML pages served to visitors in place of a redirect.
+// Each page can be overridden by a template file of the same name in the
+// workspace template directory:
+//
+//	not_found.html    unknown or deleted links          {{.ShortID}}
+//	expired.html      expired links                     {{.ShortID}}
+//	blocked.html      paused, held or geo-blocked links {{.ShortID}} {{.Reason}}
+//	takedown.html     links taken down by moderators    {{.ShortID}} {{.Reason}}
+//	unlock.html       password form                     {{.ShortID}} {{.Error}}
+//	coming_soon.html  scheduled links before go-live    {{.ShortID}} {{.ActiveAt}}
+//	preview.html      link preview                      {{.ShortID}} {{.ShortURL}} {{.LongURL}}
+//	                                                    {{.CreatedAt}} {{.ClickCount}}
+//	                                                    {{.Reasons}} (empty when reports are off)
+//	reported.html     confirmation of an abuse report   {{.ShortID}} {{.Error}}
+type Pages struct {
+	NotFound   *template.Template
+	Expired    *template.Template
+	Blocked    *template.Template
+	Takedown   *template.Template
+	Unlock     *template.Template
+	ComingSoon *template.Template
+	Preview    *template.Template
+	Reported   *template.Template
+}
+
+// DefaultPages returns the built-in pages
+func DefaultPages() *Pages {
+	return &Pages{
+		NotFound:   template.Must(template.New("not_found.html").Parse(notFoundPage)),
+		Expired:    template.Must(template.New("expired.html").Parse(expiredPage)),
+		Blocked:    template.Must(template.New("blocked.html").Parse(blockedPage)),
+		Takedown:   template.Must(template.New("takedown.html").Parse(takedownPage)),
+		Unlock:     template.Must(template.New("unlock.html").Parse(unlockPage)),
+		ComingSoon: template.Must(template.New("coming_soon.html").Parse(comingSoonPage)),
+		Preview:    template.Must(template.New("preview.html").Parse(previewPage)),
+		Reported:   template.Must(template.New("reported.html").Parse(reportedPage)),
+	}
+}
+
+// LoadPages returns the built-in pages with any templates found in dir
+// taking their place. An empty dir yields the built-in pages.
+func LoadPages(dir string) (*Pages, error) {
+	pages := DefaultPages()
+	if dir == "" {
+		return pages, nil
+	}
+
+	for name, page := range map[string]**template.Template{
+		"not_found.html":   &pages.NotFound,
+		"expired.html":     &pages.Expired,
+		"blocked.html":     &pages.Blocked,
+		"takedown.html":    &pages.Takedown,
+		"unlock.html":      &pages.Unlock,
+		"coming_soon.html": &pages.ComingSoon,
+		"preview.html":     &pages.Preview,
+		"reported.html":    &pages.Reported,
+	} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		tmpl, err := template.ParseFiles(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %v", path, err)
+		}
+		*page = tmpl
+	}
+	return pages, nil
+}
+
+// renderPage writes an HTML page rendered from tmpl
+func renderPage(c *gin.Context, status int, tmpl *template.Template, data interface{}) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(c.Writer, data); err != nil {
+		c.Error(err)
+	}
+}
+
+// wantsHTML reports whether the client prefers an HTML page over JSON, as
+// browsers do when following a link
+func wantsHTML(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// pageStyle is shared by the built-in pages
+const pageStyle = `<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<style>
+body { font-family: system-ui, sans-serif; max-width: 28rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+input, button, select, textarea { font: inherit; padding: .5rem; }
+.error { color: #b00020; }
+.destination { word-break: break-all; }
+</style>`
+
+const notFoundPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+` + pageStyle + `
+<title>Link not found</title>
+</head>
+<body>
+<h1>Link not found</h1>
+<p>There is no link at this address. Check that it was copied correctly.</p>
+</body>
+</html>
+`
+
+const expiredPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+` + pageStyle + `
+<title>Link expired</title>
+</head>
+<body>
+<h1>Link expired</h1>
+<p>This link is no longer available.</p>
+</body>
+</html>
+`
+
+const blockedPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+` + pageStyle + `
+<title>Link unavailable</title>
+</head>
+<body>
+<h1>Link unavailable</h1>
+<p>{{if .Reason}}{{.Reason}}{{else}}This link has been disabled.{{end}}</p>
+</body>
+</html>
+`
+
+const takedownPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+` + pageStyle + `
+<title>Link removed</title>
+</head>
+<body>
+<h1>Link removed</h1>
+<p>This link has been taken down {{.Reason}}.</p>
+<p>If you think this is a mistake, contact the operator of this service.</p>
+</body>
+</html>
+`
+
+const unlockPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+` + pageStyle + `
+<title>Password required</title>
+</head>
+<body>
+<h1>Password required</h1>
+<p>This link is protected. Enter the password to continue.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/{{.ShortID}}/unlock">
+<input type="password" name="password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`
+
+const comingSoonPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+` + pageStyle + `
+<title>Coming soon</title>
+</head>
+<body>
+<h1>Coming soon</h1>
+<p>This link goes live on {{.ActiveAt.Format "2 January 2006 at 15:04 MST"}}.</p>
+</body>
+</html>
+`
+
+const previewPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+` + pageStyle + `
+<title>Link preview</title>
+</head>
+<body>
+<h1>Link preview</h1>
+<p>{{.ShortURL}} goes to:</p>
+<p class="destination"><strong>{{.LongURL}}</strong></p>
+<p>Created on {{.CreatedAt.Format "2 January 2006"}} &middot; {{.ClickCount}} clicks</p>
+<p><a href="{{.ShortURL}}">Continue to destination</a></p>
+{{if .Reasons}}<details>
+<summary>Report this link</summary>
+<form method="post" action="/report/{{.ShortID}}">
+<p><select name="reason" required>
+{{range .Reasons}}<option value="{{.}}">{{.}}</option>
+{{end}}</select></p>
+<p><textarea name="details" rows="3" maxlength="1000" placeholder="Details (optional)"></textarea></p>
+<button type="submit">Send report</button>
+</form>
+</details>{{end}}
+</body>
+</html>
+`
+
+const reportedPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+` + pageStyle + `
+<title>{{if .Error}}Report not sent{{else}}Report received{{end}}</title>
+</head>
+<body>
+{{if .Error}}<h1>Report not sent</h1>
+<p class="error">{{.Error}}</p>
+{{else}}<h1>Report received</h1>
+<p>Thank you. The link will be reviewed by a moderator.</p>
+{{end}}</body>
+</html>
+`
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image/color"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/urlshortener/src/qr"
+	"github.com/yourusername/urlshortener/src/services"
+	"go.uber.org/zap"
+)
+
+// QR code rendering limits
+const (
+	defaultQRSize   = 256
+	minQRSize       = 64
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+	maxBulkQRCodes  = 100
+)
+
+// qrOptions are the rendering settings accepted by the QR code endpoints
+type qrOptions struct {
+	Format string `form:"format" json:"format"`
+	Size   int    `form:"size" json:"size"`
+	Level  string `form:"level" json:"level"`
+	Margin *int   `form:"margin" json:"margin"`
+	FG     string `form:"fg" json:"fg"`
+	BG     string `form:"bg" json:"bg"`
+}
+
+// qrStyle is a validated set of qrOptions
+type qrStyle struct {
+	format string
+	size   int
+	level  qr.Level
+	margin int
+	fg, bg color.Color
+}
+
+// style validates the options and fills in the defaults: a 256 pixel PNG
+// at level M with a four module quiet zone, black on white
+func (o qrOptions) style() (qrStyle, error) {
+	s := qrStyle{format: o.Format, size: o.Size, level: qr.LevelM, margin: defaultQRMargin}
+
+	switch s.format {
+	case "":
+		s.format = "png"
+	case "png", "svg":
+	default:
+		return s, fmt.Errorf("format must be png or svg")
+	}
+
+	if s.size == 0 {
+		s.size = defaultQRSize
+	}
+	if s.size < minQRSize || s.size > maxQRSize {
+		return s, fmt.Errorf("size must be between %d and %d", minQRSize, maxQRSize)
+	}
+
+	if o.Level != "" {
+		level, err := qr.ParseLevel(o.Level)
+		if err != nil {
+			return s, err
+		}
+		s.level = level
+	}
+
+	if o.Margin != nil {
+		s.margin = *o.Margin
+	}
+	if s.margin < 0 || s.margin > maxQRMargin {
+		return s, fmt.Errorf("margin must be between 0 and %d", maxQRMargin)
+	}
+
+	var err error
+	if s.fg, err = parseQRColor(o.FG, color.Black); err != nil {
+		return s, err
+	}
+	if s.bg, err = parseQRColor(o.BG, color.White); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func parseQRColor(value string, fallback color.Color) (color.Color, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return qr.ParseColor(value)
+}
+
+// qrContent is the URL encoded in a link's QR code. It carries the QR source
+// marker so scans can be told apart from other visits.
+func (h *URLHandler) qrContent(shortID string) string {
+	return h.baseURL + "/" + shortID + "?" + services.ClickSourceParam + "=" + services.ClickSourceQR
+}
+
+// renderQRCode encodes the short URL and renders it in the given style
+func (h *URLHandler) renderQRCode(shortID string, style qrStyle) ([]byte, error) {
+	code, err := qr.Encode(h.qrContent(shortID), style.level)
+	if err != nil {
+		return nil, err
+	}
+	if style.format == "svg" {
+		return code.SVG(style.size, style.margin, style.fg, style.bg), nil
+	}
+	return code.PNG(style.size, style.margin, style.fg, style.bg)
+}
+
+// QRCode handles requests for the QR code of a short URL. Codes can be
+// generated for links that are paused or not yet live so they can go to
+// print ahead of time.
+func (h *URLHandler) QRCode(c *gin.Context) {
+	shortID := c.Param("shortID")
+
+	var opts qrOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	style, err := opts.style()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if link, err := h.service(c).InspectURL(shortID, true); link == nil {
+		h.respondWithLinkError(c, err, shortID)
+		return
+	}
+
+	data, err := h.renderQRCode(shortID, style)
+	if err != nil {
+		h.log(c).Error("Failed to render QR code",
+			zap.Error(err),
+			zap.String("short_id", shortID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+
+	contentType := "image/png"
+	if style.format == "svg" {
+		contentType = "image/svg+xml"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.%s"`, shortID, style.format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// BulkQRCodes handles requests for the QR codes of several short URLs,
+// returned as a zip of SVG files named after the short IDs
+func (h *URLHandler) BulkQRCodes(c *gin.Context) {
+	var input struct {
+		ShortIDs []string `json:"short_ids" binding:"required,min=1"`
+		qrOptions
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.ShortIDs) > maxBulkQRCodes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d links per request", maxBulkQRCodes)})
+		return
+	}
+
+	input.Format = "svg"
+	style, err := input.qrOptions.style()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check every link before writing anything
+	var shortIDs, missing []string
+	seen := make(map[string]bool)
+	for _, shortID := range input.ShortIDs {
+		if seen[shortID] {
+			continue
+		}
+		seen[shortID] = true
+		if link, _ := h.service(c).InspectURL(shortID, true); link == nil {
+			missing = append(missing, shortID)
+			continue
+		}
+		shortIDs = append(shortIDs, shortID)
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Some links were not found", "missing": missing})
+		return
+	}
+
+	data, err := h.renderQRArchive(shortIDs, style)
+	if err != nil {
+		h.log(c).Error("Failed to render QR codes",
+			zap.Error(err),
+			zap.Strings("short_ids", shortIDs))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR codes"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="qr-codes.zip"`)
+	c.Data(http.StatusOK, "application/zip", data)
+}
+
+// renderQRArchive renders a QR code per short ID into a zip archive
+func (h *URLHandler) renderQRArchive(shortIDs []string, style qrStyle) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, shortID := range shortIDs {
+		data, err := h.renderQRCode(shortID, style)
+		if err != nil {
+			return nil, err
+		}
+		w, err := archive.Create(shortID + "." + style.format)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Quota handles requests for the caller's plan, rate limits and monthly
+// link usage
+func (h *URLHandler) Quota(c *gin.Context) {
+	owner := requestOwner(c)
+	usage, err := h.service(c).LinkQuota(owner)
+	if err != nil {
+		h.log(c).Error("Failed to count link usage",
+			zap.Error(err),
+			zap.String("owner", owner))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"owner": owner, "links": usage}
+	if h.ratePolicy != nil {
+		policy := h.ratePolicy.Load()
+		ip := c.ClientIP()
+		subject := policy.Subject(owner, ip)
+		limits := []gin.H{}
+		for _, rule := range policy.Rules(subject.Plan) {
+			limits = append(limits, gin.H{
+				"route":  rule.Route,
+				"rate":   rule.Rate,
+				"period": rule.Period.String(),
+				"burst":  rule.Limit().Capacity(),
+			})
+		}
+		response["plan"] = subject.Plan
+		response["workspace"] = subject.Workspace
+		response["rate_limits"] = limits
+		response["allowlisted"] = policy.Allowlisted(ip)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/urlshortener/src/models"
+	"github.com/yourusername/urlshortener/src/services"
+	"go.uber.org/zap"
+)
+
+// adminActor is recorded in the audit log for actions taken through the
+// moderation endpoints
+const adminActor = "admin"
+
+// takedownReasons explains why a link was taken down, by disabled reason
+var takedownReasons = map[string]string{
+	services.DisabledReasonUnsafe: "because its destination was found to be unsafe",
+	"phishing":                    "because it was used for phishing",
+	"malware":                     "because it pointed to malware",
+	"spam":                        "because it was used for spam",
+	"illegal":                     "because it pointed to illegal content",
+}
+
+// takedownReason returns the explanation shown on the takedown page
+func takedownReason(reason string) string {
+	if text, ok := takedownReasons[reason]; ok {
+		return text
+	}
+	return "for violating the terms of service"
+}
+
+// RequireAdmin rejects requests that do not carry the admin token as a
+// bearer token. Without a configured token the endpoints are unavailable.
+func (h *URLHandler) RequireAdmin(c *gin.Context) {
+	if h.adminToken == "" {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "admin endpoints are not configured"})
+		return
+	}
+
+	if !h.isAdmin(c) {
+		h.log(c).Warn("Rejected admin request",
+			zap.String("path", c.Request.URL.Path),
+			zap.String("ip", c.ClientIP()))
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "admin token required"})
+		return
+	}
+	c.Next()
+}
+
+// isAdmin reports whether the request carries the admin token as a bearer
+// token
+func (h *URLHandler) isAdmin(c *gin.Context) bool {
+	if h.adminToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
+}
+
+// ReportURL handles abuse reports from visitors. It accepts JSON from API
+// clients and form posts from the preview page.
+func (h *URLHandler) ReportURL(c *gin.Context) {
+	shortID := c.Param("shortID")
+
+	var input struct {
+		Reason  string `json:"reason" form:"reason"`
+		Details string `json:"details" form:"details"`
+	}
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	report, err := h.service(c).ReportURL(shortID, input.Reason, input.Details, c.ClientIP())
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidReportReason), errors.Is(err, services.ErrReportTooLong):
+			status = http.StatusBadRequest
+		case errors.Is(err, services.ErrURLNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrTooManyReports):
+			status = http.StatusTooManyRequests
+		default:
+			h.log(c).Error("Failed to report URL",
+				zap.Error(err),
+				zap.String("short_id", shortID))
+		}
+		h.respondWithPage(c, status, h.pages.Reported,
+			gin.H{"ShortID": shortID, "Error": err.Error()}, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondWithPage(c, http.StatusCreated, h.pages.Reported,
+		gin.H{"ShortID": shortID},
+		gin.H{"id": report.ID, "short_id": report.ShortID, "reason": report.Reason, "status": report.Status})
+}
+
+// ListReports handles requests for the moderation queue. The status query
+// parameter picks open, triaged or resolved reports; without it every
+// unresolved report is listed.
+func (h *URLHandler) ListReports(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", models.ReportStatusOpen, models.ReportStatusTriaged, models.ReportStatusResolved:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, triaged or resolved"})
+		return
+	}
+
+	reports, err := h.service(c).ListReports(status)
+	if err != nil {
+		h.log(c).Error("Failed to list reports",
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+// TriageReport handles requests to mark a report as being looked at
+func (h *URLHandler) TriageReport(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	report, err := h.service(c).TriageReport(id, input.Note)
+	if err != nil {
+		h.respondWithReportError(c, err, id)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ResolveReport handles requests to act on a report and close it
+func (h *URLHandler) ResolveReport(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action is required"})
+		return
+	}
+
+	report, err := h.service(c).ResolveReport(id, input.Action, adminActor, input.Note)
+	if err != nil {
+		h.respondWithReportError(c, err, id)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// reportID parses the report ID in the request path, responding with 400
+// when it is not a number
+func reportID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid report id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondWithReportError writes the error response for a failed moderation action
+func (h *URLHandler) respondWithReportError(c *gin.Context, err error, id uint) {
+	switch {
+	case errors.Is(err, services.ErrReportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAction):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReportResolved), errors.Is(err, services.ErrNoOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.log(c).Error("Moderation action failed",
+			zap.Error(err),
+			zap.Uint("report_id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
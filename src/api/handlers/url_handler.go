@@ -1,115 +1,1051 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yourusername/urlshortener/src/logger"
+	"github.com/yourusername/urlshortener/src/metrics"
 	"github.com/yourusername/urlshortener/src/models"
+	"github.com/yourusername/urlshortener/src/ratelimit"
+	"github.com/yourusername/urlshortener/src/services"
 	"go.uber.org/zap"
 )
 
 // URLService defines the interface for URL operations
 type URLService interface {
-	CreateShortURL(longURL string, expiresAt *time.Time) (*models.URL, error)
-	GetLongURL(shortID string) (string, error)
+	CreateShortURL(longURL string, opts services.LinkOptions) (*models.URL, error)
+	UpdateURL(shortID string, update services.LinkUpdate) (*models.URL, error)
+	ResolveURL(shortID string, unlocked bool) (*models.URL, error)
+	InspectURL(shortID string, unlocked bool) (*models.URL, error)
+	CheckGeoFencing(r *http.Request) (string, bool)
+	UnlockURL(shortID, password, ip, userAgent string) error
+	IssueUnlockToken(shortID string) (string, time.Time)
+	VerifyUnlockToken(shortID, token string) bool
+	LinkOwner(shortID string) (string, error)
+	DeleteURL(shortID string) error
+	RestoreURL(shortID string) error
+	SetURLStatus(shortID, status string) error
+	ApproveURL(shortID string) error
+	ListHeldURLs() ([]models.URL, error)
+	AuditLog(shortID string) ([]models.AuditEntry, error)
+	ReportURL(shortID, reason, details, ip string) (*models.Report, error)
+	ListReports(status string) ([]models.Report, error)
+	TriageReport(id uint, note string) (*models.Report, error)
+	ResolveReport(id uint, action, actor, note string) (*models.Report, error)
+	LinkQuota(owner string) (services.QuotaUsage, error)
+	ListURLs() (active, scheduled []models.URL, err error)
+	ListDeletedURLs() ([]models.URL, error)
+	CreateShortURLs(reqs []services.LinkRequest, atomic bool) ([]services.LinkResult, error)
 }
 
+// ClickRecorder records visits to short URLs
+type ClickRecorder interface {
+	RecordClick(urlID uint, r *http.Request) error
+}
+
+// URLHandlerConfig holds the settings for a URLHandler
+type URLHandlerConfig struct {
+	// BaseURL is prepended to short IDs to build short URLs
+	BaseURL string
+	// PrelaunchURL is where visitors to scheduled links without their own
+	// prelaunch URL are sent. When empty a "coming soon" page is served.
+	PrelaunchURL string
+	// Pages are served to browsers in place of a redirect. Nil means the
+	// built-in pages.
+	Pages *Pages
+	// DefaultRedirect fills in whatever a link's own redirect profile leaves
+	// unset. Without a status code, links redirect with 302 Found.
+	DefaultRedirect models.RedirectProfile
+	// Clicks records each successful redirect. Nil disables click recording.
+	Clicks ClickRecorder
+	// MaxBatchItems caps the number of items in a batch shorten request.
+	// Zero means the default of 500.
+	MaxBatchItems int
+	// AdminToken is the bearer token required by the moderation endpoints.
+	// When empty those endpoints are unavailable.
+	AdminToken string
+	// RatePolicy is described to callers of GET /quota; nil leaves it out
+	RatePolicy *ratelimit.PolicySource
+	// DisableReports leaves the report form out of preview pages
+	DisableReports bool
+	// Settings returns the running configuration for GET /admin/config. It
+	// must redact secrets. Nil leaves the endpoint unavailable.
+	Settings func() interface{}
+	// Reload reloads the configuration for POST /admin/reload, returning
+	// what changed. Nil leaves the endpoint unavailable.
+	Reload func() (interface{}, error)
+	// WithContext binds the URL service to a request's context so that its
+	// work is traced as part of the request. Nil uses the service as is.
+	WithContext func(ctx context.Context) URLService
+}
+
+// defaultMaxBatchItems is the batch size limit when none is configured
+const defaultMaxBatchItems = 500
+
 // URLHandler handles URL-related HTTP requests
 type URLHandler struct {
-	urlService URLService
-	logger     *zap.Logger
-	baseURL    string
+	urlService    URLService
+	logger        *zap.Logger
+	baseURL       string
+	prelaunchURL  string
+	pages         *Pages
+	redirect      models.RedirectProfile
+	clicks        ClickRecorder
+	maxBatchItems int
+	adminToken    string
+	ratePolicy    *ratelimit.PolicySource
+	reasons       []string
+	settings      func() interface{}
+	reload        func() (interface{}, error)
+	withContext   func(ctx context.Context) URLService
 }
 
 // NewURLHandler creates a new URL handler
-func NewURLHandler(urlService URLService, baseURL string) *URLHandler {
+func NewURLHandler(urlService URLService, cfg URLHandlerConfig) *URLHandler {
+	pages := cfg.Pages
+	if pages == nil {
+		pages = DefaultPages()
+	}
+	redirect := cfg.DefaultRedirect
+	if redirect.StatusCode == 0 {
+		redirect.StatusCode = http.StatusFound
+	}
+	maxBatchItems := cfg.MaxBatchItems
+	if maxBatchItems <= 0 {
+		maxBatchItems = defaultMaxBatchItems
+	}
+	reasons := services.ReportReasons
+	if cfg.DisableReports {
+		reasons = nil
+	}
+
 	return &URLHandler{
-		urlService: urlService,
-		logger:     logger.Get(),
-		baseURL:    baseURL,
+		urlService:    urlService,
+		logger:        logger.Get(),
+		baseURL:       cfg.BaseURL,
+		prelaunchURL:  cfg.PrelaunchURL,
+		pages:         pages,
+		redirect:      redirect,
+		clicks:        cfg.Clicks,
+		maxBatchItems: maxBatchItems,
+		adminToken:    cfg.AdminToken,
+		ratePolicy:    cfg.RatePolicy,
+		reasons:       reasons,
+		settings:      cfg.Settings,
+		reload:        cfg.Reload,
+		withContext:   cfg.WithContext,
 	}
 }
 
-// ShortenURL handles requests to create a shortened URL
-func (h *URLHandler) ShortenURL(c *gin.Context) {
-	var input struct {
-		URL           string `json:"url" binding:"required"`
-		ExpirationDays int    `json:"expiration_days"`
+// log returns the handler's logger with the ID of the request in c
+func (h *URLHandler) log(c *gin.Context) *zap.Logger {
+	return logger.With(c.Request.Context(), h.logger)
+}
+
+// service returns the URL service bound to the request in c
+func (h *URLHandler) service(c *gin.Context) URLService {
+	if h.withContext == nil {
+		return h.urlService
+	}
+	return h.withContext(c.Request.Context())
+}
+
+// shortenRequest is the body of a request to create a short URL, and one
+// item of a batch
+type shortenRequest struct {
+	URL                 string                 `json:"url" binding:"required"`
+	Alias               string                 `json:"alias"`
+	Tags                []string               `json:"tags"`
+	Reuse               bool                   `json:"reuse"`
+	ExpirationDays      int                    `json:"expiration_days"`
+	ExpiresAt           *time.Time             `json:"expires_at"`
+	ExpiresIn           string                 `json:"expires_in"`
+	MaxClicks           int                    `json:"max_clicks"`
+	ExpireAfterInactive int                    `json:"expire_after_inactive"`
+	Password            string                 `json:"password"`
+	NotBefore           *time.Time             `json:"not_before"`
+	PrelaunchURL        string                 `json:"prelaunch_url"`
+	ExpiredRedirectURL  string                 `json:"expired_redirect_url"`
+	Redirect            models.RedirectProfile `json:"redirect"`
 }
 
+// shortenError is a problem with a shorten request, with a stable code that
+// batch clients can act on
+type shortenError struct {
+	code    string
+	message string
+}
+
+func (e *shortenError) Error() string {
+	return e.message
+}
+
+// linkRequest validates the request and converts it for the URL service,
+// on behalf of owner
+func (r *shortenRequest) linkRequest(owner string) (services.LinkRequest, error) {
+	if r.URL == "" {
+		return services.LinkRequest{}, &shortenError{"invalid_url", "url is required"}
+	}
+	if _, err := url.ParseRequestURI(r.URL); err != nil {
+		return services.LinkRequest{}, &shortenError{"invalid_url", "Invalid URL format"}
+	}
+
+	// Work out the expiration time from whichever form was given
+	expiresAt, err := parseExpiry(r.ExpirationDays, r.ExpiresAt, r.ExpiresIn)
+	if err != nil {
+		return services.LinkRequest{}, &shortenError{"invalid_expiry", err.Error()}
+	}
+	if r.PrelaunchURL != "" {
+		if _, err := url.ParseRequestURI(r.PrelaunchURL); err != nil {
+			return services.LinkRequest{}, &shortenError{"invalid_url", "Invalid prelaunch_url format"}
+		}
+	}
+	if r.ExpiredRedirectURL != "" {
+		if _, err := url.ParseRequestURI(r.ExpiredRedirectURL); err != nil {
+			return services.LinkRequest{}, &shortenError{"invalid_url", "Invalid expired_redirect_url format"}
+		}
+	}
+	if r.MaxClicks < 0 || r.ExpireAfterInactive < 0 {
+		return services.LinkRequest{}, &shortenError{"invalid_limit", "max_clicks and expire_after_inactive must not be negative"}
+	}
+
+	return services.LinkRequest{
+		LongURL: r.URL,
+		Options: services.LinkOptions{
+			ExpiresAt:           expiresAt,
+			MaxClicks:           r.MaxClicks,
+			ExpireAfterInactive: r.ExpireAfterInactive,
+			Password:            r.Password,
+			NotBefore:           r.NotBefore,
+			PrelaunchURL:        r.PrelaunchURL,
+			ExpiredRedirectURL:  r.ExpiredRedirectURL,
+			Redirect:            r.Redirect,
+			Alias:               r.Alias,
+			Tags:                r.Tags,
+			Owner:               owner,
+			Reuse:               r.Reuse,
+		},
+	}, nil
+}
+
+// apiKeyHeader carries the caller's API key
+const apiKeyHeader = "X-API-Key"
+
+// requestOwner returns the owner of links created by this request
+func requestOwner(c *gin.Context) string {
+	return services.KeyOwner(c.GetHeader(apiKeyHeader))
+}
+
+// shortenErrorCode maps an error from creating a short URL to its code and
+// HTTP status. Unexpected errors map to internal_error.
+func shortenErrorCode(err error) (string, int) {
+	var reqErr *shortenError
+	switch {
+	case errors.As(err, &reqErr):
+		return reqErr.code, http.StatusBadRequest
+	case errors.Is(err, services.ErrExpiryInPast), errors.Is(err, services.ErrExpiryTooLong),
+		errors.Is(err, services.ErrInvalidWindow):
+		return "invalid_expiry", http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidURL):
+		return "invalid_url", http.StatusBadRequest
+	case errors.Is(err, services.ErrDestinationRejected):
+		return "destination_rejected", http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidRedirect):
+		return "invalid_redirect", http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidAlias):
+		return "invalid_alias", http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidTags):
+		return "invalid_tags", http.StatusBadRequest
+	case errors.Is(err, services.ErrOwnerBanned):
+		return "key_banned", http.StatusForbidden
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return "quota_exceeded", http.StatusTooManyRequests
+	case errors.Is(err, services.ErrAliasTaken):
+		return "alias_taken", http.StatusConflict
+	case errors.Is(err, services.ErrBatchAborted):
+		return "aborted", http.StatusConflict
+	}
+	return "internal_error", http.StatusInternalServerError
+}
+
+// errorResponse is the JSON body for a rejected request. Destinations
+// refused by the destination policy also carry the reason.
+func errorResponse(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var violation *services.PolicyViolation
+	if errors.As(err, &violation) {
+		body["reason"] = violation.Reason
+	}
+	return body
+}
+
+// linkResponse is the JSON description of a newly created short URL
+func (h *URLHandler) linkResponse(link *models.URL) gin.H {
+	return gin.H{
+		"short_url":             h.baseURL + "/" + link.ShortID,
+		"long_url":              link.LongURL,
+		"original_url":          link.OriginalURL,
+		"expires_at":            link.ExpiresAt,
+		"max_clicks":            link.MaxClicks,
+		"expire_after_inactive": link.ExpireAfterInactive,
+		"password_protected":    link.PasswordProtected(),
+		"not_before":            link.NotBefore,
+		"redirect":              link.Redirect,
+		"tags":                  link.Tags,
+		"status":                link.Status,
+		"risk_score":            link.RiskScore,
+		"risk_signals":          link.RiskSignals,
+	}
+}
+
+// ShortenURL handles requests to create a shortened URL
+func (h *URLHandler) ShortenURL(c *gin.Context) {
+	var input shortenRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		h.logger.Warn("Invalid input for URL shortening",
+		h.log(c).Warn("Invalid input for URL shortening",
 			zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	// Validate URL
-	if _, err := url.ParseRequestURI(input.URL); err != nil {
-		h.logger.Warn("Invalid URL format",
+	req, err := input.linkRequest(requestOwner(c))
+	if err != nil {
+		h.log(c).Warn("Invalid URL shortening request",
 			zap.Error(err),
 			zap.String("url", input.URL))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL format"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Set expiration time if specified
-	var expiresAt *time.Time
-	if input.ExpirationDays > 0 {
-		t := time.Now().AddDate(0, 0, input.ExpirationDays)
-		expiresAt = &t
-	}
-
 	// Create shortened URL
-	shortURL, err := h.urlService.CreateShortURL(input.URL, expiresAt)
+	shortURL, err := h.service(c).CreateShortURL(req.LongURL, req.Options)
 	if err != nil {
-		h.logger.Error("Failed to create short URL",
+		if _, status := shortenErrorCode(err); status != http.StatusInternalServerError {
+			c.JSON(status, errorResponse(err))
+			return
+		}
+		h.log(c).Error("Failed to create short URL",
 			zap.Error(err),
 			zap.String("long_url", input.URL))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	h.logger.Info("URL shortened successfully",
+	h.log(c).Info("URL shortened successfully",
 		zap.String("short_id", shortURL.ShortID),
 		zap.String("long_url", shortURL.LongURL),
 		zap.Timep("expires_at", shortURL.ExpiresAt))
 
-	// Construct the full shortened URL using the base URL
-	shortenedURL := h.baseURL + "/" + shortURL.ShortID
+	// Links held for moderation are created but not live yet
+	status := http.StatusOK
+	if shortURL.Status == models.URLStatusHeld {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, h.linkResponse(shortURL))
+}
+
+// ShortenBatch handles requests to create many short URLs at once. Every
+// item gets a result in input order; with "atomic" set, either all items
+// are created or none are.
+func (h *URLHandler) ShortenBatch(c *gin.Context) {
+	var input struct {
+		Atomic bool              `json:"atomic"`
+		Items  []json.RawMessage `json:"items" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.log(c).Warn("Invalid input for batch shortening",
+			zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if len(input.Items) > h.maxBatchItems {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("at most %d items per batch", h.maxBatchItems)})
+		return
+	}
+
+	// Items that fail validation here are reported without reaching the service
+	results := make([]gin.H, len(input.Items))
+	errs := make([]error, len(input.Items))
+	owner := requestOwner(c)
+	var reqs []services.LinkRequest
+	var positions []int
+	for i, raw := range input.Items {
+		var item shortenRequest
+		if err := json.Unmarshal(raw, &item); err != nil {
+			errs[i] = &shortenError{"invalid_input", "Invalid input"}
+			continue
+		}
+		req, err := item.linkRequest(owner)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		reqs = append(reqs, req)
+		positions = append(positions, i)
+	}
+
+	// In atomic mode an invalid item fails the whole batch up front
+	atomicFailed := input.Atomic && len(reqs) < len(input.Items)
+	if atomicFailed {
+		for _, i := range positions {
+			errs[i] = services.ErrBatchAborted
+		}
+	} else if len(reqs) > 0 {
+		linkResults, err := h.service(c).CreateShortURLs(reqs, input.Atomic)
+		if err != nil {
+			h.log(c).Error("Failed to create URL batch",
+				zap.Error(err),
+				zap.Int("items", len(reqs)))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create short URLs"})
+			return
+		}
+		for k, result := range linkResults {
+			i := positions[k]
+			if result.Err != nil {
+				errs[i] = result.Err
+				continue
+			}
+			results[i] = h.linkResponse(result.URL)
+		}
+	}
+
+	created := 0
+	for i := range results {
+		if errs[i] == nil {
+			results[i]["index"] = i
+			results[i]["status"] = "created"
+			created++
+			continue
+		}
+		code, _ := shortenErrorCode(errs[i])
+		message := errs[i].Error()
+		if code == "internal_error" {
+			message = "Failed to create short URL"
+		}
+		results[i] = errorResponse(errs[i])
+		results[i]["index"] = i
+		results[i]["status"] = "error"
+		results[i]["code"] = code
+		results[i]["error"] = message
+	}
+
+	h.log(c).Info("Processed shorten batch",
+		zap.Int("items", len(results)),
+		zap.Int("created", created),
+		zap.Bool("atomic", input.Atomic))
 
 	c.JSON(http.StatusOK, gin.H{
-		"short_url": shortenedURL,
-		"long_url":  shortURL.LongURL,
-		"expires_at": shortURL.ExpiresAt,
+		"created": created,
+		"failed":  len(results) - created,
+		"results": results,
 	})
 }
 
+// parseExpiry converts the mutually exclusive expiry inputs into an absolute
+// time. It returns nil when none was given so the server default applies.
+func parseExpiry(days int, expiresAt *time.Time, expiresIn string) (*time.Time, error) {
+	given := 0
+	if days != 0 {
+		given++
+	}
+	if expiresAt != nil {
+		given++
+	}
+	if expiresIn != "" {
+		given++
+	}
+	if given > 1 {
+		return nil, errors.New("only one of expiration_days, expires_at and expires_in may be set")
+	}
+
+	switch {
+	case days < 0:
+		return nil, errors.New("expiration_days must be positive")
+	case days > 0:
+		t := time.Now().AddDate(0, 0, days)
+		return &t, nil
+	case expiresIn != "":
+		d, err := time.ParseDuration(expiresIn)
+		if err != nil || d <= 0 {
+			return nil, errors.New("expires_in must be a positive duration such as \"36h\"")
+		}
+		t := time.Now().Add(d)
+		return &t, nil
+	}
+	return expiresAt, nil
+}
+
 // RedirectToLongURL handles requests to redirect to the original URL
 func (h *URLHandler) RedirectToLongURL(c *gin.Context) {
 	shortID := c.Param("shortID")
 	if shortID == "" {
-		h.logger.Warn("Empty short ID provided")
+		h.log(c).Warn("Empty short ID provided")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Short ID is required"})
 		return
 	}
 
+	// A trailing "+" asks for a preview instead of a redirect
+	if strings.HasSuffix(shortID, "+") {
+		h.PreviewURL(c, strings.TrimSuffix(shortID, "+"))
+		return
+	}
+
+	if !h.checkGeoFencing(c, shortID) {
+		metrics.Redirects.WithLabelValues(metrics.RedirectBlocked).Inc()
+		return
+	}
+
 	// Get the original URL
-	longURL, err := h.urlService.GetLongURL(shortID)
+	link, err := h.service(c).ResolveURL(shortID, h.isUnlocked(c, shortID))
 	if err != nil {
-		h.logger.Warn("URL not found or expired",
-			zap.Error(err),
-			zap.String("short_id", shortID))
-		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found or expired"})
+		metrics.Redirects.WithLabelValues(redirectOutcome(err)).Inc()
+		h.respondUnresolvable(c, shortID, link, err)
 		return
 	}
+	metrics.Redirects.WithLabelValues(metrics.RedirectHit).Inc()
+
+	if h.clicks != nil {
+		if err := h.clicks.RecordClick(link.ID, c.Request); err != nil {
+			h.log(c).Error("Failed to record click",
+				zap.Error(err),
+				zap.String("short_id", shortID))
+		}
+	}
 
-	h.logger.Info("Redirecting to long URL",
+	profile := link.Redirect.WithDefaults(h.redirect)
+	h.log(c).Info("Redirecting to long URL",
 		zap.String("short_id", shortID),
-		zap.String("long_url", longURL))
+		zap.String("long_url", link.LongURL),
+		zap.Int("status", profile.StatusCode))
+
+	applyRedirectHeaders(c, profile)
+	c.Redirect(profile.StatusCode, link.LongURL)
+}
+
+// PreviewURL renders a page showing where a short URL goes, without
+// following it or counting a click
+func (h *URLHandler) PreviewURL(c *gin.Context, shortID string) {
+	if !h.checkGeoFencing(c, shortID) {
+		return
+	}
+
+	link, err := h.service(c).InspectURL(shortID, h.isUnlocked(c, shortID))
+	if err != nil {
+		h.respondUnresolvable(c, shortID, link, err)
+		return
+	}
+
+	renderPage(c, http.StatusOK, h.pages.Preview, gin.H{
+		"ShortID":    link.ShortID,
+		"ShortURL":   h.baseURL + "/" + link.ShortID,
+		"LongURL":    link.LongURL,
+		"CreatedAt":  link.CreatedAt.UTC(),
+		"ClickCount": link.ClickCount,
+		"Reasons":    h.reasons,
+	})
+}
+
+// ExpandURL handles requests for the metadata of a short URL, without
+// following it or counting a click
+func (h *URLHandler) ExpandURL(c *gin.Context) {
+	shortURL := c.Query("short_url")
+	if shortURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "short_url is required"})
+		return
+	}
+
+	shortID, ok := h.shortIDFromURL(shortURL)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "short_url is not a short URL of this service"})
+		return
+	}
+
+	if _, allowed := h.service(c).CheckGeoFencing(c.Request); !allowed {
+		c.JSON(http.StatusUnavailableForLegalReasons, gin.H{"error": services.ErrURLGeoBlocked.Error()})
+		return
+	}
+
+	link, err := h.service(c).InspectURL(shortID, h.isUnlocked(c, shortID))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrURLNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrPasswordRequired):
+			status = http.StatusUnauthorized
+		case errors.Is(err, services.ErrURLExpired), errors.Is(err, services.ErrURLPaused),
+			errors.Is(err, services.ErrURLDisabled):
+			status = http.StatusGone
+		case errors.Is(err, services.ErrURLNotYetActive):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, services.ErrURLHeld):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"short_id":           link.ShortID,
+		"short_url":          h.baseURL + "/" + link.ShortID,
+		"long_url":           link.LongURL,
+		"status":             link.Status,
+		"created_at":         link.CreatedAt,
+		"expires_at":         link.ExpiresAt,
+		"not_before":         link.NotBefore,
+		"click_count":        link.ClickCount,
+		"max_clicks":         link.MaxClicks,
+		"password_protected": link.PasswordProtected(),
+	})
+}
+
+// shortIDFromURL extracts the short ID from a short URL or bare short ID.
+// Full URLs must point at this service.
+func (h *URLHandler) shortIDFromURL(raw string) (string, bool) {
+	if !strings.Contains(raw, "/") {
+		return raw, raw != ""
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if base, err := url.Parse(h.baseURL); err == nil && base.Host != "" && !strings.EqualFold(parsed.Host, base.Host) {
+		return "", false
+	}
+
+	shortID := strings.TrimSuffix(strings.Trim(parsed.Path, "/"), "+")
+	if shortID == "" || strings.Contains(shortID, "/") {
+		return "", false
+	}
+	return shortID, true
+}
+
+// isUnlocked reports whether the request carries a valid unlock cookie for shortID
+func (h *URLHandler) isUnlocked(c *gin.Context, shortID string) bool {
+	token, err := c.Cookie(unlockCookieName(shortID))
+	return err == nil && h.service(c).VerifyUnlockToken(shortID, token)
+}
+
+// checkGeoFencing responds with the blocked page and returns false when the
+// visitor's region may not follow links
+func (h *URLHandler) checkGeoFencing(c *gin.Context, shortID string) bool {
+	country, allowed := h.service(c).CheckGeoFencing(c.Request)
+	if !allowed {
+		h.log(c).Warn("Blocked visit from restricted region",
+			zap.String("short_id", shortID),
+			zap.String("country", country))
+		h.respondUnresolvable(c, shortID, nil, services.ErrURLGeoBlocked)
+	}
+	return allowed
+}
+
+// applyRedirectHeaders sets the caching, referrer and robots headers for a redirect
+func applyRedirectHeaders(c *gin.Context, profile models.RedirectProfile) {
+	if profile.CacheMaxAge != nil && *profile.CacheMaxAge > 0 {
+		c.Header("Cache-Control", "private, max-age="+strconv.Itoa(*profile.CacheMaxAge))
+	} else {
+		c.Header("Cache-Control", "no-store")
+	}
+	if profile.ReferrerPolicy != "" {
+		c.Header("Referrer-Policy", profile.ReferrerPolicy)
+	}
+	if profile.NoIndex != nil && *profile.NoIndex {
+		c.Header("X-Robots-Tag", "noindex")
+	}
+}
+
+// redirectOutcome returns the redirect metrics outcome of a visit to a link
+// that cannot be followed
+func redirectOutcome(err error) string {
+	switch {
+	case errors.Is(err, services.ErrURLNotFound):
+		return metrics.RedirectMiss
+	case errors.Is(err, services.ErrURLExpired):
+		return metrics.RedirectExpired
+	case errors.Is(err, services.ErrURLPaused), errors.Is(err, services.ErrURLHeld),
+		errors.Is(err, services.ErrURLDisabled), errors.Is(err, services.ErrURLGeoBlocked):
+		return metrics.RedirectBlocked
+	case errors.Is(err, services.ErrPasswordRequired):
+		return metrics.RedirectLocked
+	case errors.Is(err, services.ErrURLNotYetActive):
+		return metrics.RedirectScheduled
+	default:
+		return metrics.RedirectError
+	}
+}
 
-	c.Redirect(http.StatusMovedPermanently, longURL)
-} 
\ No newline at end of file
+// respondUnresolvable responds to a visit to a link that cannot be followed.
+// Browsers get an HTML page and API clients get JSON; links with a fallback
+// URL for their state are redirected there instead.
+func (h *URLHandler) respondUnresolvable(c *gin.Context, shortID string, link *models.URL, err error) {
+	switch {
+	case errors.Is(err, services.ErrPasswordRequired):
+		h.respondWithPage(c, http.StatusUnauthorized, h.pages.Unlock,
+			gin.H{"ShortID": shortID}, gin.H{"error": err.Error()})
+
+	case errors.Is(err, services.ErrURLNotYetActive):
+		fallback := link.PrelaunchURL
+		if fallback == "" {
+			fallback = h.prelaunchURL
+		}
+		if fallback != "" {
+			c.Redirect(http.StatusFound, fallback)
+			return
+		}
+		if wait := time.Until(*link.NotBefore); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		}
+		h.respondWithPage(c, http.StatusServiceUnavailable, h.pages.ComingSoon,
+			gin.H{"ShortID": shortID, "ActiveAt": link.NotBefore.UTC()},
+			gin.H{"error": err.Error(), "not_before": link.NotBefore})
+
+	case errors.Is(err, services.ErrURLPaused):
+		h.respondWithPage(c, http.StatusGone, h.pages.Blocked,
+			gin.H{"ShortID": shortID}, gin.H{"error": err.Error()})
+
+	case errors.Is(err, services.ErrURLHeld):
+		h.respondWithPage(c, http.StatusForbidden, h.pages.Blocked,
+			gin.H{"ShortID": shortID, "Reason": "This link is awaiting review."},
+			gin.H{"error": err.Error()})
+
+	case errors.Is(err, services.ErrURLDisabled):
+		reason := ""
+		if link != nil {
+			reason = link.DisabledReason
+		}
+		h.respondWithPage(c, http.StatusGone, h.pages.Takedown,
+			gin.H{"ShortID": shortID, "Reason": takedownReason(reason)},
+			gin.H{"error": err.Error(), "reason": reason})
+
+	case errors.Is(err, services.ErrURLGeoBlocked):
+		h.respondWithPage(c, http.StatusUnavailableForLegalReasons, h.pages.Blocked,
+			gin.H{"ShortID": shortID, "Reason": "This link is not available in your region."},
+			gin.H{"error": err.Error()})
+
+	case errors.Is(err, services.ErrURLExpired):
+		if link != nil && link.ExpiredRedirectURL != "" {
+			c.Redirect(http.StatusFound, link.ExpiredRedirectURL)
+			return
+		}
+		h.respondWithPage(c, http.StatusGone, h.pages.Expired,
+			gin.H{"ShortID": shortID}, gin.H{"error": err.Error()})
+
+	default:
+		if !errors.Is(err, services.ErrURLNotFound) {
+			h.log(c).Error("Failed to resolve URL",
+				zap.Error(err),
+				zap.String("short_id", shortID))
+		}
+		h.respondWithPage(c, http.StatusNotFound, h.pages.NotFound,
+			gin.H{"ShortID": shortID}, gin.H{"error": "URL not found"})
+	}
+}
+
+// respondWithPage renders page for browsers and writes body as JSON for everyone else
+func (h *URLHandler) respondWithPage(c *gin.Context, status int, page *template.Template, data, body gin.H) {
+	if wantsHTML(c) {
+		renderPage(c, status, page, data)
+		return
+	}
+	c.JSON(status, body)
+}
+
+// UnlockURL handles password submissions for protected URLs. A correct
+// password sets a short-lived cookie and sends the visitor back to the link.
+func (h *URLHandler) UnlockURL(c *gin.Context) {
+	shortID := c.Param("shortID")
+	err := h.service(c).UnlockURL(shortID, c.PostForm("password"), c.ClientIP(), c.Request.UserAgent())
+	switch {
+	case errors.Is(err, services.ErrPasswordIncorrect):
+		renderPage(c, http.StatusUnauthorized, h.pages.Unlock, gin.H{"ShortID": shortID, "Error": "Incorrect password."})
+		return
+	case errors.Is(err, services.ErrTooManyAttempts):
+		renderPage(c, http.StatusTooManyRequests, h.pages.Unlock, gin.H{"ShortID": shortID, "Error": "Too many failed attempts. Try again later."})
+		return
+	case err != nil:
+		h.respondWithLinkError(c, err, shortID)
+		return
+	}
+
+	token, expires := h.service(c).IssueUnlockToken(shortID)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     unlockCookieName(shortID),
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		// TLS usually ends at a proxy, so go by the public base URL
+		Secure:   strings.HasPrefix(strings.ToLower(h.baseURL), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusSeeOther, "/"+shortID)
+}
+
+// unlockCookieName returns the name of the cookie that unlocks shortID
+func unlockCookieName(shortID string) string {
+	return "unlock_" + shortID
+}
+
+// UpdateURL handles requests to change the destination or redirect profile of a URL
+func (h *URLHandler) UpdateURL(c *gin.Context) {
+	shortID := c.Param("shortID")
+	if !h.authorizeLink(c, shortID) {
+		return
+	}
+
+	var input struct {
+		URL      *string                 `json:"url"`
+		Redirect *models.RedirectProfile `json:"redirect"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if input.URL != nil {
+		if _, err := url.ParseRequestURI(*input.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL format"})
+			return
+		}
+	}
+
+	link, err := h.service(c).UpdateURL(shortID, services.LinkUpdate{
+		LongURL:  input.URL,
+		Redirect: input.Redirect,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRedirect) || errors.Is(err, services.ErrInvalidURL) ||
+			errors.Is(err, services.ErrDestinationRejected) {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		h.respondWithLinkError(c, err, shortID)
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
+
+// DeleteURL handles requests to move a URL to the trash
+func (h *URLHandler) DeleteURL(c *gin.Context) {
+	shortID := c.Param("shortID")
+	if !h.authorizeLink(c, shortID) {
+		return
+	}
+	if err := h.service(c).DeleteURL(shortID); err != nil {
+		h.respondWithLinkError(c, err, shortID)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RestoreURL handles requests to restore a URL from the trash
+func (h *URLHandler) RestoreURL(c *gin.Context) {
+	shortID := c.Param("shortID")
+	if !h.authorizeLink(c, shortID) {
+		return
+	}
+	if err := h.service(c).RestoreURL(shortID); err != nil {
+		h.respondWithLinkError(c, err, shortID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"short_id": shortID, "status": "restored"})
+}
+
+// PauseURL handles requests to temporarily disable a URL
+func (h *URLHandler) PauseURL(c *gin.Context) {
+	h.setStatus(c, models.URLStatusPaused)
+}
+
+// ResumeURL handles requests to re-enable a paused URL
+func (h *URLHandler) ResumeURL(c *gin.Context) {
+	h.setStatus(c, models.URLStatusActive)
+}
+
+// ListURLs handles requests to list live URLs, with scheduled URLs listed
+// separately. The admin sees every URL and API key holders see their own.
+func (h *URLHandler) ListURLs(c *gin.Context) {
+	admin, owner := h.isAdmin(c), requestOwner(c)
+	if !admin && owner == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+		return
+	}
+
+	active, scheduled, err := h.service(c).ListURLs()
+	if err != nil {
+		h.log(c).Error("Failed to list URLs",
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !admin {
+		active, scheduled = ownedBy(active, owner), ownedBy(scheduled, owner)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"links": h.listedLinks(active), "scheduled": h.listedLinks(scheduled)})
+}
+
+// listedLinks describes links in a listing. The destination of a password
+// protected link is left out, so listing it does not bypass the password.
+func (h *URLHandler) listedLinks(links []models.URL) []gin.H {
+	listed := make([]gin.H, 0, len(links))
+	for _, link := range links {
+		item := gin.H{
+			"short_id":           link.ShortID,
+			"short_url":          h.baseURL + "/" + link.ShortID,
+			"status":             link.Status,
+			"created_at":         link.CreatedAt,
+			"expires_at":         link.ExpiresAt,
+			"not_before":         link.NotBefore,
+			"click_count":        link.ClickCount,
+			"max_clicks":         link.MaxClicks,
+			"tags":               link.Tags,
+			"password_protected": link.PasswordProtected(),
+		}
+		if !link.PasswordProtected() {
+			item["long_url"] = link.LongURL
+		}
+		listed = append(listed, item)
+	}
+	return listed
+}
+
+// ownedBy returns the links belonging to owner
+func ownedBy(links []models.URL, owner string) []models.URL {
+	owned := make([]models.URL, 0, len(links))
+	for _, link := range links {
+		if link.Owner == owner {
+			owned = append(owned, link)
+		}
+	}
+	return owned
+}
+
+// ListTrash handles requests to list deleted URLs awaiting purge. The admin
+// sees every deleted URL and API key holders see their own.
+func (h *URLHandler) ListTrash(c *gin.Context) {
+	admin, owner := h.isAdmin(c), requestOwner(c)
+	if !admin && owner == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+		return
+	}
+
+	urls, err := h.service(c).ListDeletedURLs()
+	if err != nil {
+		h.log(c).Error("Failed to list deleted URLs",
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !admin {
+		urls = ownedBy(urls, owner)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"links": urls})
+}
+
+// ListHeldURLs handles requests to list links held for moderation
+func (h *URLHandler) ListHeldURLs(c *gin.Context) {
+	urls, err := h.service(c).ListHeldURLs()
+	if err != nil {
+		h.log(c).Error("Failed to list held URLs",
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"links": urls})
+}
+
+// ApproveURL handles requests to release a link held for moderation
+func (h *URLHandler) ApproveURL(c *gin.Context) {
+	shortID := c.Param("shortID")
+	if err := h.service(c).ApproveURL(shortID); err != nil {
+		h.respondWithLinkError(c, err, shortID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"short_id": shortID, "status": models.URLStatusActive})
+}
+
+// AuditLog handles requests for the audit entries of a link
+func (h *URLHandler) AuditLog(c *gin.Context) {
+	shortID := c.Param("shortID")
+	entries, err := h.service(c).AuditLog(shortID)
+	if err != nil {
+		h.respondWithLinkError(c, err, shortID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"short_id": shortID, "entries": entries})
+}
+
+// setStatus updates the status of the URL named in the request path
+func (h *URLHandler) setStatus(c *gin.Context, status string) {
+	shortID := c.Param("shortID")
+	if !h.authorizeLink(c, shortID) {
+		return
+	}
+	if err := h.service(c).SetURLStatus(shortID, status); err != nil {
+		h.respondWithLinkError(c, err, shortID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"short_id": shortID, "status": status})
+}
+
+// authorizeLink lets the admin and the API key that created shortID manage
+// it. Anyone else gets an error response and false.
+func (h *URLHandler) authorizeLink(c *gin.Context, shortID string) bool {
+	if h.isAdmin(c) {
+		return true
+	}
+	owner := requestOwner(c)
+	if owner == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+		return false
+	}
+
+	linkOwner, err := h.service(c).LinkOwner(shortID)
+	if err != nil {
+		h.respondWithLinkError(c, err, shortID)
+		return false
+	}
+	if linkOwner != owner {
+		h.log(c).Warn("Rejected request for another key's link",
+			zap.String("short_id", shortID),
+			zap.String("path", c.Request.URL.Path))
+		c.JSON(http.StatusForbidden, gin.H{"error": "link belongs to another API key"})
+		return false
+	}
+	return true
+}
+
+// respondWithLinkError writes the error response for a failed link operation
+func (h *URLHandler) respondWithLinkError(c *gin.Context, err error, shortID string) {
+	if errors.Is(err, services.ErrURLNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+	if errors.Is(err, services.ErrURLHeld) || errors.Is(err, services.ErrURLDisabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.log(c).Error("Link operation failed",
+		zap.Error(err),
+		zap.String("short_id", shortID))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -1,17 +1,30 @@
 package handlers
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/urlshortener/src/metrics"
 	"github.com/yourusername/urlshortener/src/models"
+	"github.com/yourusername/urlshortener/src/ratelimit"
+	"github.com/yourusername/urlshortener/src/services"
+	"gorm.io/gorm"
 )
 
 // MockURLService is a mock implementation of URLService
@@ -20,21 +33,204 @@ type MockURLService struct {
 }
 
 // CreateShortURL implements the URLService interface
-func (m *MockURLService) CreateShortURL(longURL string, expiresAt *time.Time) (*models.URL, error) {
-	args := m.Called(longURL, expiresAt)
+func (m *MockURLService) CreateShortURL(longURL string, opts services.LinkOptions) (*models.URL, error) {
+	args := m.Called(longURL, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.URL), args.Error(1)
 }
 
-// GetLongURL implements the URLService interface
-func (m *MockURLService) GetLongURL(shortID string) (string, error) {
+// UpdateURL implements the URLService interface
+func (m *MockURLService) UpdateURL(shortID string, update services.LinkUpdate) (*models.URL, error) {
+	args := m.Called(shortID, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.URL), args.Error(1)
+}
+
+// ResolveURL implements the URLService interface
+func (m *MockURLService) ResolveURL(shortID string, unlocked bool) (*models.URL, error) {
+	args := m.Called(shortID, unlocked)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.URL), args.Error(1)
+}
+
+// UnlockURL implements the URLService interface
+func (m *MockURLService) UnlockURL(shortID, password, ip, userAgent string) error {
+	args := m.Called(shortID, password, ip, userAgent)
+	return args.Error(0)
+}
+
+// IssueUnlockToken implements the URLService interface
+func (m *MockURLService) IssueUnlockToken(shortID string) (string, time.Time) {
+	args := m.Called(shortID)
+	return args.String(0), args.Get(1).(time.Time)
+}
+
+// VerifyUnlockToken implements the URLService interface
+func (m *MockURLService) VerifyUnlockToken(shortID, token string) bool {
+	args := m.Called(shortID, token)
+	return args.Bool(0)
+}
+
+// LinkOwner implements the URLService interface
+func (m *MockURLService) LinkOwner(shortID string) (string, error) {
 	args := m.Called(shortID)
 	return args.String(0), args.Error(1)
 }
 
+// DeleteURL implements the URLService interface
+func (m *MockURLService) DeleteURL(shortID string) error {
+	args := m.Called(shortID)
+	return args.Error(0)
+}
+
+// RestoreURL implements the URLService interface
+func (m *MockURLService) RestoreURL(shortID string) error {
+	args := m.Called(shortID)
+	return args.Error(0)
+}
+
+// SetURLStatus implements the URLService interface
+func (m *MockURLService) SetURLStatus(shortID, status string) error {
+	args := m.Called(shortID, status)
+	return args.Error(0)
+}
+
+// ApproveURL implements the URLService interface
+func (m *MockURLService) ApproveURL(shortID string) error {
+	args := m.Called(shortID)
+	return args.Error(0)
+}
+
+// ListHeldURLs implements the URLService interface
+func (m *MockURLService) ListHeldURLs() ([]models.URL, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.URL), args.Error(1)
+}
+
+// AuditLog implements the URLService interface
+func (m *MockURLService) AuditLog(shortID string) ([]models.AuditEntry, error) {
+	args := m.Called(shortID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.AuditEntry), args.Error(1)
+}
+
+// ReportURL implements the URLService interface
+func (m *MockURLService) ReportURL(shortID, reason, details, ip string) (*models.Report, error) {
+	args := m.Called(shortID, reason, details, ip)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Report), args.Error(1)
+}
+
+// ListReports implements the URLService interface
+func (m *MockURLService) ListReports(status string) ([]models.Report, error) {
+	args := m.Called(status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Report), args.Error(1)
+}
+
+// TriageReport implements the URLService interface
+func (m *MockURLService) TriageReport(id uint, note string) (*models.Report, error) {
+	args := m.Called(id, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Report), args.Error(1)
+}
+
+// ResolveReport implements the URLService interface
+func (m *MockURLService) ResolveReport(id uint, action, actor, note string) (*models.Report, error) {
+	args := m.Called(id, action, actor, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Report), args.Error(1)
+}
+
+// LinkQuota implements the URLService interface
+func (m *MockURLService) LinkQuota(owner string) (services.QuotaUsage, error) {
+	args := m.Called(owner)
+	return args.Get(0).(services.QuotaUsage), args.Error(1)
+}
+
+// ListURLs implements the URLService interface
+func (m *MockURLService) ListURLs() ([]models.URL, []models.URL, error) {
+	args := m.Called()
+	return args.Get(0).([]models.URL), args.Get(1).([]models.URL), args.Error(2)
+}
+
+// ListDeletedURLs implements the URLService interface
+func (m *MockURLService) ListDeletedURLs() ([]models.URL, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.URL), args.Error(1)
+}
+
+// CreateShortURLs implements the URLService interface
+func (m *MockURLService) CreateShortURLs(reqs []services.LinkRequest, atomic bool) ([]services.LinkResult, error) {
+	args := m.Called(reqs, atomic)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]services.LinkResult), args.Error(1)
+}
+
+// InspectURL implements the URLService interface
+func (m *MockURLService) InspectURL(shortID string, unlocked bool) (*models.URL, error) {
+	args := m.Called(shortID, unlocked)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.URL), args.Error(1)
+}
+
+// CheckGeoFencing implements the URLService interface
+func (m *MockURLService) CheckGeoFencing(r *http.Request) (string, bool) {
+	args := m.Called(r)
+	return args.String(0), args.Bool(1)
+}
+
+// newMockURLService creates a mock service that allows visits from any region
+func newMockURLService() *MockURLService {
+	m := new(MockURLService)
+	m.On("CheckGeoFencing", mock.Anything).Return("US", true).Maybe()
+	return m
+}
+
 // Helper function to create a time pointer
+// recordedClick is a click seen by clickRecorder
+type recordedClick struct {
+	urlID uint
+	query string
+}
+
+// clickRecorder is a ClickRecorder that keeps the clicks in memory
+type clickRecorder struct {
+	clicks []recordedClick
+}
+
+// RecordClick implements the ClickRecorder interface
+func (r *clickRecorder) RecordClick(urlID uint, req *http.Request) error {
+	r.clicks = append(r.clicks, recordedClick{urlID: urlID, query: req.URL.RawQuery})
+	return nil
+}
+
 func timePtr(t time.Time) *time.Time {
 	return &t
 }
@@ -66,6 +262,35 @@ func TestShortenURL(t *testing.T) {
 			expectedCode:  http.StatusOK,
 			expectedError: false,
 		},
+		{
+			name: "Duration expiry with click cap",
+			input: map[string]interface{}{
+				"url":        "https://www.google.com",
+				"expires_in": "36h",
+				"max_clicks": 5,
+			},
+			mockResponse: &models.URL{
+				ShortID:   "def456",
+				LongURL:   "https://www.google.com",
+				ExpiresAt: timePtr(time.Now().Add(36 * time.Hour)),
+				MaxClicks: 5,
+			},
+			mockError:     nil,
+			expectedCode:  http.StatusOK,
+			expectedError: false,
+		},
+		{
+			name: "Conflicting expiry fields",
+			input: map[string]interface{}{
+				"url":             "https://www.google.com",
+				"expiration_days": 30,
+				"expires_in":      "36h",
+			},
+			mockResponse:  nil,
+			mockError:     nil,
+			expectedCode:  http.StatusBadRequest,
+			expectedError: true,
+		},
 		{
 			name: "Invalid URL",
 			input: map[string]interface{}{
@@ -82,13 +307,13 @@ func TestShortenURL(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create mock service
-			mockService := new(MockURLService)
+			mockService := newMockURLService()
 			if !tt.expectedError {
 				mockService.On("CreateShortURL", mock.Anything, mock.Anything).Return(tt.mockResponse, tt.mockError)
 			}
 
 			// Create handler
-			handler := NewURLHandler(mockService)
+			handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
 
 			// Create test request
 			body, _ := json.Marshal(tt.input)
@@ -112,7 +337,7 @@ func TestShortenURL(t *testing.T) {
 				var response map[string]interface{}
 				err := json.Unmarshal(w.Body.Bytes(), &response)
 				assert.NoError(t, err)
-				assert.Equal(t, tt.mockResponse.ShortID, response["short_url"])
+				assert.Equal(t, "http://localhost:8080/"+tt.mockResponse.ShortID, response["short_url"])
 				assert.Equal(t, tt.mockResponse.LongURL, response["long_url"])
 			}
 
@@ -130,6 +355,9 @@ func TestRedirectToLongURL(t *testing.T) {
 		name          string
 		shortID       string
 		mockLongURL   string
+		notBefore     *time.Time
+		prelaunchURL  string
+		expiredURL    string
 		mockError     error
 		expectedCode  int
 		expectedError bool
@@ -139,7 +367,7 @@ func TestRedirectToLongURL(t *testing.T) {
 			shortID:       "abc123",
 			mockLongURL:   "https://www.google.com",
 			mockError:     nil,
-			expectedCode:  http.StatusMovedPermanently,
+			expectedCode:  http.StatusFound,
 			expectedError: false,
 		},
 		{
@@ -158,18 +386,77 @@ func TestRedirectToLongURL(t *testing.T) {
 			expectedCode:  http.StatusNotFound,
 			expectedError: true,
 		},
+		{
+			name:          "Password Protected Short ID",
+			shortID:       "secret1",
+			mockLongURL:   "",
+			mockError:     services.ErrPasswordRequired,
+			expectedCode:  http.StatusUnauthorized,
+			expectedError: true,
+		},
+		{
+			name:          "Paused Short ID",
+			shortID:       "paused1",
+			mockLongURL:   "",
+			mockError:     services.ErrURLPaused,
+			expectedCode:  http.StatusGone,
+			expectedError: true,
+		},
+		{
+			name:          "Scheduled Short ID",
+			shortID:       "launch1",
+			mockLongURL:   "https://www.google.com",
+			notBefore:     timePtr(time.Now().Add(time.Hour)),
+			mockError:     services.ErrURLNotYetActive,
+			expectedCode:  http.StatusServiceUnavailable,
+			expectedError: true,
+		},
+		{
+			name:          "Scheduled Short ID With Prelaunch URL",
+			shortID:       "launch2",
+			mockLongURL:   "https://www.google.com",
+			notBefore:     timePtr(time.Now().Add(time.Hour)),
+			prelaunchURL:  "https://www.google.com/soon",
+			mockError:     services.ErrURLNotYetActive,
+			expectedCode:  http.StatusFound,
+			expectedError: true,
+		},
+		{
+			name:          "Expired Short ID",
+			shortID:       "old1",
+			mockLongURL:   "https://www.google.com",
+			mockError:     services.ErrURLExpired,
+			expectedCode:  http.StatusGone,
+			expectedError: true,
+		},
+		{
+			name:          "Expired Short ID With Fallback",
+			shortID:       "old2",
+			mockLongURL:   "https://www.google.com",
+			expiredURL:    "https://www.google.com/archive",
+			mockError:     services.ErrURLExpired,
+			expectedCode:  http.StatusFound,
+			expectedError: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create mock service
-			mockService := new(MockURLService)
+			mockService := newMockURLService()
 			if tt.shortID != "" {
-				mockService.On("GetLongURL", tt.shortID).Return(tt.mockLongURL, tt.mockError)
+				link := &models.URL{
+					ShortID:            tt.shortID,
+					LongURL:            tt.mockLongURL,
+					NotBefore:          tt.notBefore,
+					PrelaunchURL:       tt.prelaunchURL,
+					ExpiredRedirectURL: tt.expiredURL,
+				}
+				mockService.On("ResolveURL", tt.shortID, false).Return(link, tt.mockError)
 			}
 
 			// Create handler
-			handler := NewURLHandler(mockService)
+			handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
 
 			// Create test request
 			req := httptest.NewRequest(http.MethodGet, "/"+tt.shortID, nil)
@@ -191,9 +478,1049 @@ func TestRedirectToLongURL(t *testing.T) {
 			if !tt.expectedError {
 				assert.Equal(t, tt.mockLongURL, w.Header().Get("Location"))
 			}
+			if tt.prelaunchURL != "" {
+				assert.Equal(t, tt.prelaunchURL, w.Header().Get("Location"))
+			}
+			if tt.expiredURL != "" {
+				assert.Equal(t, tt.expiredURL, w.Header().Get("Location"))
+			}
+
+			// Verify mock expectations
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRedirectProfile(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	intPtr := func(i int) *int { return &i }
+	boolPtr := func(b bool) *bool { return &b }
+
+	defaults := models.RedirectProfile{
+		StatusCode:     http.StatusFound,
+		CacheMaxAge:    intPtr(0),
+		ReferrerPolicy: "strict-origin-when-cross-origin",
+		NoIndex:        boolPtr(false),
+	}
+
+	tests := []struct {
+		name                   string
+		profile                models.RedirectProfile
+		expectedCode           int
+		expectedCacheControl   string
+		expectedReferrerPolicy string
+		expectedRobotsTag      string
+	}{
+		{
+			name:                   "Server default",
+			profile:                models.RedirectProfile{},
+			expectedCode:           http.StatusFound,
+			expectedCacheControl:   "no-store",
+			expectedReferrerPolicy: "strict-origin-when-cross-origin",
+			expectedRobotsTag:      "",
+		},
+		{
+			name: "Permanent, cached, anonymized and not indexed",
+			profile: models.RedirectProfile{
+				StatusCode:     http.StatusPermanentRedirect,
+				CacheMaxAge:    intPtr(3600),
+				ReferrerPolicy: "no-referrer",
+				NoIndex:        boolPtr(true),
+			},
+			expectedCode:           http.StatusPermanentRedirect,
+			expectedCacheControl:   "private, max-age=3600",
+			expectedReferrerPolicy: "no-referrer",
+			expectedRobotsTag:      "noindex",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock service
+			mockService := newMockURLService()
+			link := &models.URL{ShortID: "abc123", LongURL: "https://www.google.com", Redirect: tt.profile}
+			mockService.On("ResolveURL", "abc123", false).Return(link, nil)
+
+			// Create handler
+			handler := NewURLHandler(mockService, URLHandlerConfig{DefaultRedirect: defaults})
+
+			// Create Gin context
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			c.Params = []gin.Param{{Key: "shortID", Value: "abc123"}}
+
+			// Call handler
+			handler.RedirectToLongURL(c)
+
+			// Assert response
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, "https://www.google.com", w.Header().Get("Location"))
+			assert.Equal(t, tt.expectedCacheControl, w.Header().Get("Cache-Control"))
+			assert.Equal(t, tt.expectedReferrerPolicy, w.Header().Get("Referrer-Policy"))
+			assert.Equal(t, tt.expectedRobotsTag, w.Header().Get("X-Robots-Tag"))
+		})
+	}
+}
+
+func TestRedirectErrorPages(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	// A workspace template replaces the built-in 404 page
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "not_found.html"), []byte(`<p>No link at /{{.ShortID}}</p>`), 0644)
+	assert.NoError(t, err)
+	pages, err := LoadPages(dir)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		accept       string
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:         "Browser",
+			accept:       "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			expectedType: "text/html; charset=utf-8",
+			expectedBody: "<p>No link at /missing</p>",
+		},
+		{
+			name:         "API client",
+			accept:       "application/json",
+			expectedType: "application/json; charset=utf-8",
+			expectedBody: `{"error":"URL not found"}`,
+		},
+		{
+			name:         "No Accept header",
+			accept:       "",
+			expectedType: "application/json; charset=utf-8",
+			expectedBody: `{"error":"URL not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock service
+			mockService := newMockURLService()
+			mockService.On("ResolveURL", "missing", false).Return(nil, services.ErrURLNotFound)
+
+			// Create handler
+			handler := NewURLHandler(mockService, URLHandlerConfig{Pages: pages})
+
+			// Create Gin context
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/missing", nil)
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+			c.Params = []gin.Param{{Key: "shortID", Value: "missing"}}
+
+			// Call handler
+			handler.RedirectToLongURL(c)
+
+			// Assert response
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, tt.expectedType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestPreviewAndExpandURL(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+
+	link := &models.URL{
+		Model:      gorm.Model{CreatedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		ShortID:    "abc123",
+		LongURL:    "https://www.google.com/search?q=preview",
+		Status:     models.URLStatusActive,
+		ClickCount: 42,
+	}
+
+	t.Run("Preview", func(t *testing.T) {
+		mockService := newMockURLService()
+		mockService.On("InspectURL", "abc123", false).Return(link, nil)
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/abc123+", nil)
+		c.Params = []gin.Param{{Key: "shortID", Value: "abc123+"}}
+
+		handler.RedirectToLongURL(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "https://www.google.com/search?q=preview")
+		assert.Contains(t, w.Body.String(), "42 clicks")
+		mockService.AssertNotCalled(t, "ResolveURL", mock.Anything, mock.Anything)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Expand", func(t *testing.T) {
+		mockService := newMockURLService()
+		mockService.On("InspectURL", "abc123", false).Return(link, nil)
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/expand?short_url=http://localhost:8080/abc123", nil)
+
+		handler.ExpandURL(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "https://www.google.com/search?q=preview", response["long_url"])
+		assert.Equal(t, float64(42), response["click_count"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Expand foreign URL", func(t *testing.T) {
+		mockService := newMockURLService()
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/expand?short_url=https://bit.ly/abc123", nil)
+
+		handler.ExpandURL(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Geo-fenced preview", func(t *testing.T) {
+		mockService := new(MockURLService)
+		mockService.On("CheckGeoFencing", mock.Anything).Return("RU", false)
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/abc123+", nil)
+		c.Params = []gin.Param{{Key: "shortID", Value: "abc123+"}}
+
+		handler.RedirectToLongURL(c)
+
+		assert.Equal(t, http.StatusUnavailableForLegalReasons, w.Code)
+		mockService.AssertNotCalled(t, "InspectURL", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeleteURL(t *testing.T) {
+	// Set Gin to test mode
+	gin.SetMode(gin.TestMode)
+	owner := services.KeyOwner("owner-key")
+
+	tests := []struct {
+		name         string
+		shortID      string
+		apiKey       string
+		admin        bool
+		linkOwner    string
+		ownerError   error
+		mockError    error
+		deletes      bool
+		expectedCode int
+	}{
+		{
+			name:         "Owner deletes",
+			shortID:      "abc123",
+			apiKey:       "owner-key",
+			linkOwner:    owner,
+			deletes:      true,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Admin deletes",
+			shortID:      "abc123",
+			admin:        true,
+			deletes:      true,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Non-existent Short ID",
+			shortID:      "nonexistent",
+			apiKey:       "owner-key",
+			ownerError:   services.ErrURLNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Another key",
+			shortID:      "abc123",
+			apiKey:       "other-key",
+			linkOwner:    owner,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Link without owner",
+			shortID:      "abc123",
+			apiKey:       "other-key",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "No API key",
+			shortID:      "abc123",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create mock service
+			mockService := newMockURLService()
+			if tt.apiKey != "" {
+				mockService.On("LinkOwner", tt.shortID).Return(tt.linkOwner, tt.ownerError)
+			}
+			if tt.deletes {
+				mockService.On("DeleteURL", tt.shortID).Return(tt.mockError)
+			}
+
+			// Create handler
+			handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080", AdminToken: "secret"})
+
+			// Create Gin context
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/links/"+tt.shortID, nil)
+			if tt.apiKey != "" {
+				c.Request.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.admin {
+				c.Request.Header.Set("Authorization", "Bearer secret")
+			}
+			c.Params = []gin.Param{{Key: "shortID", Value: tt.shortID}}
+
+			// Call handler
+			handler.DeleteURL(c)
+			c.Writer.WriteHeaderNow()
+
+			// Assert response
+			assert.Equal(t, tt.expectedCode, w.Code)
 
 			// Verify mock expectations
 			mockService.AssertExpectations(t)
+			if !tt.deletes {
+				mockService.AssertNotCalled(t, "DeleteURL", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestLinkManagementRequiresOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := newMockURLService()
+	mockService.On("LinkOwner", "abc123").Return(services.KeyOwner("owner-key"), nil)
+	mockService.On("SetURLStatus", "abc123", models.URLStatusPaused).Return(nil)
+	mockService.On("ListDeletedURLs").Return([]models.URL{
+		{ShortID: "mine", Owner: services.KeyOwner("owner-key")},
+		{ShortID: "theirs", Owner: services.KeyOwner("other-key")},
+		{ShortID: "anonymous"},
+	}, nil)
+	handler := NewURLHandler(mockService, URLHandlerConfig{AdminToken: "secret"})
+
+	request := func(call gin.HandlerFunc, header, value string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+		c.Request = httptest.NewRequest(http.MethodPost, "/links/abc123", nil)
+		if header != "" {
+			c.Request.Header.Set(header, value)
+		}
+		call(c)
+		return w
+	}
+
+	// Other keys cannot pause, resume or restore the link
+	for _, call := range []gin.HandlerFunc{handler.PauseURL, handler.ResumeURL, handler.RestoreURL} {
+		assert.Equal(t, http.StatusForbidden, request(call, "X-API-Key", "other-key").Code)
+		assert.Equal(t, http.StatusUnauthorized, request(call, "", "").Code)
+	}
+	assert.Equal(t, http.StatusOK, request(handler.PauseURL, "X-API-Key", "owner-key").Code)
+
+	// The trash lists the caller's own links, or every link for the admin
+	trash := func(header, value string) []string {
+		w := request(handler.ListTrash, header, value)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Links []models.URL `json:"links"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		var ids []string
+		for _, link := range response.Links {
+			ids = append(ids, link.ShortID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"mine"}, trash("X-API-Key", "owner-key"))
+	assert.Equal(t, []string{"mine", "theirs", "anonymous"}, trash("Authorization", "Bearer secret"))
+	assert.Equal(t, http.StatusUnauthorized, request(handler.ListTrash, "", "").Code)
+
+	mockService.AssertNotCalled(t, "SetURLStatus", "abc123", models.URLStatusActive)
+	mockService.AssertNotCalled(t, "RestoreURL", mock.Anything)
+}
+
+func TestUpdateURLRequiresOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := newMockURLService()
+	mockService.On("LinkOwner", "abc123").Return(services.KeyOwner("owner-key"), nil)
+	destination := "https://example.com/new"
+	mockService.On("UpdateURL", "abc123", services.LinkUpdate{LongURL: &destination}).
+		Return(&models.URL{ShortID: "abc123", LongURL: destination}, nil)
+	handler := NewURLHandler(mockService, URLHandlerConfig{AdminToken: "secret"})
+
+	patch := func(header, value string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+		c.Request = httptest.NewRequest(http.MethodPatch, "/links/abc123", strings.NewReader(`{"url":"`+destination+`"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			c.Request.Header.Set(header, value)
+		}
+		handler.UpdateURL(c)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, patch("", ""))
+	assert.Equal(t, http.StatusForbidden, patch("X-API-Key", "other-key"))
+	mockService.AssertNotCalled(t, "UpdateURL", mock.Anything, mock.Anything)
+
+	assert.Equal(t, http.StatusOK, patch("X-API-Key", "owner-key"))
+	assert.Equal(t, http.StatusOK, patch("Authorization", "Bearer secret"))
+}
+
+func TestListURLs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := newMockURLService()
+	mine := services.KeyOwner("owner-key")
+	mockService.On("ListURLs").Return([]models.URL{
+		{ShortID: "open", LongURL: "https://example.com/open", Owner: mine},
+		{ShortID: "locked", LongURL: "https://example.com/secret", PasswordHash: "hash", Owner: mine},
+		{ShortID: "theirs", LongURL: "https://example.com/theirs", Owner: services.KeyOwner("other-key")},
+	}, []models.URL{
+		{ShortID: "soon", LongURL: "https://example.com/soon"},
+	}, nil)
+	handler := NewURLHandler(mockService, URLHandlerConfig{AdminToken: "secret"})
+
+	type listing struct {
+		Links     []map[string]interface{} `json:"links"`
+		Scheduled []map[string]interface{} `json:"scheduled"`
+	}
+	list := func(header, value string) (int, listing) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/links", nil)
+		if header != "" {
+			c.Request.Header.Set(header, value)
+		}
+		handler.ListURLs(c)
+		var body listing
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		}
+		return w.Code, body
+	}
+
+	code, _ := list("", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Key holders see their own links, without the destination of protected ones
+	code, body := list("X-API-Key", "owner-key")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, body.Links, 2)
+	assert.Equal(t, "https://example.com/open", body.Links[0]["long_url"])
+	assert.Equal(t, "locked", body.Links[1]["short_id"])
+	assert.Equal(t, true, body.Links[1]["password_protected"])
+	assert.NotContains(t, body.Links[1], "long_url")
+	assert.Empty(t, body.Scheduled)
+
+	// The admin sees every link
+	code, body = list("Authorization", "Bearer secret")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, body.Links, 3)
+	assert.Len(t, body.Scheduled, 1)
+}
+
+func TestUnlockCookieSecure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := newMockURLService()
+	mockService.On("UnlockURL", "abc123", "hunter2", mock.Anything, mock.Anything).Return(nil)
+	mockService.On("IssueUnlockToken", "abc123").Return("token", time.Now().Add(time.Minute))
+
+	unlock := func(baseURL string) *http.Cookie {
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: baseURL})
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+		// TLS ends at a proxy, so the request itself is plain HTTP
+		c.Request = httptest.NewRequest(http.MethodPost, "/abc123/unlock", strings.NewReader("password=hunter2"))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler.UnlockURL(c)
+		c.Writer.WriteHeaderNow()
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		return cookies[0]
+	}
+
+	assert.True(t, unlock("https://sho.rt").Secure)
+	assert.False(t, unlock("http://localhost:8080").Secure)
+}
+
+func TestRedirectRecordsClick(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	link := &models.URL{Model: gorm.Model{ID: 7}, ShortID: "abc123", LongURL: "https://www.google.com"}
+	mockService := newMockURLService()
+	mockService.On("ResolveURL", "abc123", false).Return(link, nil)
+	clicks := &clickRecorder{}
+	handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080", Clicks: clicks})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123?src=qr", nil)
+	c.Params = []gin.Param{{Key: "shortID", Value: "abc123"}}
+
+	handler.RedirectToLongURL(c)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, []recordedClick{{urlID: 7, query: "src=qr"}}, clicks.clicks)
+}
+
+func TestQRCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	link := &models.URL{ShortID: "abc123", LongURL: "https://www.google.com", Status: models.URLStatusPaused}
+
+	tests := []struct {
+		name           string
+		query          string
+		found          bool
+		expectedStatus int
+		expectedType   string
+	}{
+		{"Default PNG", "", true, http.StatusOK, "image/png"},
+		{"Styled SVG", "?format=svg&size=512&level=H&margin=2&fg=%23336699&bg=ffffee", true, http.StatusOK, "image/svg+xml"},
+		{"Invalid format", "?format=gif", true, http.StatusBadRequest, ""},
+		{"Invalid level", "?level=X", true, http.StatusBadRequest, ""},
+		{"Size too large", "?size=5000", true, http.StatusBadRequest, ""},
+		{"Invalid color", "?fg=blue", true, http.StatusBadRequest, ""},
+		{"Not found", "", false, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := newMockURLService()
+			if tt.found {
+				// Paused links still get a code, so InspectURL's error is ignored
+				mockService.On("InspectURL", "abc123", true).Return(link, services.ErrURLPaused).Maybe()
+			} else {
+				mockService.On("InspectURL", "abc123", true).Return(nil, services.ErrURLNotFound)
+			}
+			handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/abc123/qr"+tt.query, nil)
+			c.Params = []gin.Param{{Key: "shortID", Value: "abc123"}}
+
+			handler.QRCode(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedType == "" {
+				return
+			}
+			assert.Equal(t, tt.expectedType, w.Header().Get("Content-Type"))
+			if tt.expectedType == "image/png" {
+				img, err := png.Decode(w.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, 256, img.Bounds().Dx())
+			} else {
+				assert.Contains(t, w.Body.String(), `width="512"`)
+				assert.Contains(t, w.Body.String(), `fill="#336699"`)
+			}
 		})
 	}
-} 
\ No newline at end of file
+}
+
+func TestBulkQRCodes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(handler *URLHandler, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/links/qr", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		handler.BulkQRCodes(c)
+		return w
+	}
+
+	t.Run("Zip of SVGs", func(t *testing.T) {
+		mockService := newMockURLService()
+		mockService.On("InspectURL", "abc123", true).Return(&models.URL{ShortID: "abc123"}, nil)
+		mockService.On("InspectURL", "def456", true).Return(&models.URL{ShortID: "def456"}, nil)
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w := request(handler, `{"short_ids": ["abc123", "def456", "abc123"], "size": 300}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		assert.NoError(t, err)
+		var names []string
+		for _, file := range archive.File {
+			names = append(names, file.Name)
+			f, err := file.Open()
+			assert.NoError(t, err)
+			data, _ := io.ReadAll(f)
+			f.Close()
+			assert.Contains(t, string(data), `width="300"`)
+		}
+		assert.Equal(t, []string{"abc123.svg", "def456.svg"}, names)
+	})
+
+	t.Run("Missing links", func(t *testing.T) {
+		mockService := newMockURLService()
+		mockService.On("InspectURL", "abc123", true).Return(&models.URL{ShortID: "abc123"}, nil)
+		mockService.On("InspectURL", "nope", true).Return(nil, services.ErrURLNotFound)
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w := request(handler, `{"short_ids": ["abc123", "nope"]}`)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"missing":["nope"]`)
+	})
+
+	t.Run("Empty list", func(t *testing.T) {
+		handler := NewURLHandler(newMockURLService(), URLHandlerConfig{BaseURL: "http://localhost:8080"})
+		w := request(handler, `{"short_ids": []}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestShortenURLReuse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := newMockURLService()
+	mockService.On("CreateShortURL", "https://www.google.com", mock.MatchedBy(func(opts services.LinkOptions) bool {
+		return opts.Reuse && opts.Owner == services.KeyOwner("secret-key")
+	})).Return(&models.URL{ShortID: "abc123", LongURL: "https://www.google.com"}, nil)
+	handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(`{"url": "https://www.google.com", "reuse": true}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("X-API-Key", "secret-key")
+
+	handler.ShortenURL(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestShortenURLRejectedDestination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := newMockURLService()
+	mockService.On("CreateShortURL", "http://169.254.169.254/", mock.Anything).Return(nil,
+		&services.PolicyViolation{Reason: services.ReasonPrivateNetwork, Detail: "address 169.254.169.254 is on a private network"})
+	handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(`{"url": "http://169.254.169.254/"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.ShortenURL(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "private_network", response["reason"])
+	mockService.AssertExpectations(t)
+}
+
+func TestHeldLinks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	held := &models.URL{ShortID: "abc123", LongURL: "https://paypal-login.example/", Status: models.URLStatusHeld,
+		RiskScore: 60, RiskSignals: []string{services.SignalBrandSpoof}}
+	mockService := newMockURLService()
+	mockService.On("CreateShortURL", held.LongURL, mock.Anything).Return(held, nil)
+	mockService.On("ResolveURL", "abc123", false).Return(held, services.ErrURLHeld)
+	mockService.On("SetURLStatus", "abc123", models.URLStatusActive).Return(services.ErrURLHeld)
+	mockService.On("ApproveURL", "abc123").Return(nil)
+	handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080", AdminToken: "secret"})
+
+	// Creating a risky link is accepted but the link is held
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(`{"url": "https://paypal-login.example/"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler.ShortenURL(c)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "held", response["status"])
+	assert.Equal(t, []interface{}{"brand_spoof"}, response["risk_signals"])
+
+	// Visitors cannot follow it
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	handler.RedirectToLongURL(c)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Resuming does not release it, approving does
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+	c.Request = httptest.NewRequest(http.MethodPost, "/links/abc123/resume", nil)
+	c.Request.Header.Set("Authorization", "Bearer secret")
+	handler.ResumeURL(c)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+	handler.ApproveURL(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestDisabledLink(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	link := &models.URL{ShortID: "abc123", LongURL: "https://malware.example/", Status: models.URLStatusDisabled,
+		DisabledReason: services.DisabledReasonUnsafe}
+	mockService := newMockURLService()
+	mockService.On("ResolveURL", "abc123", false).Return(link, services.ErrURLDisabled)
+	mockService.On("AuditLog", "abc123").Return([]models.AuditEntry{
+		{ShortID: "abc123", Action: models.AuditLinkDisabled, Actor: "reputation_scan", Detail: "flagged as unsafe: MALWARE"},
+	}, nil)
+	handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	c.Request.Header.Set("Accept", "text/html")
+	handler.RedirectToLongURL(c)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), "taken down because its destination was found to be unsafe")
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+	handler.AuditLog(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"action":"link.disabled"`)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestReports(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Report from API client", func(t *testing.T) {
+		mockService := newMockURLService()
+		mockService.On("ReportURL", "abc123", "phishing", "asks for my password", "192.0.2.1").
+			Return(&models.Report{ID: 7, ShortID: "abc123", Reason: "phishing", Status: models.ReportStatusOpen}, nil)
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+		c.Request = httptest.NewRequest(http.MethodPost, "/report/abc123",
+			bytes.NewBufferString(`{"reason":"phishing","details":"asks for my password"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		handler.ReportURL(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":7`)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Report form from browser", func(t *testing.T) {
+		mockService := newMockURLService()
+		mockService.On("ReportURL", "abc123", "spam", "", "192.0.2.1").Return(nil, services.ErrTooManyReports)
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "shortID", Value: "abc123"}}
+		c.Request = httptest.NewRequest(http.MethodPost, "/report/abc123", strings.NewReader("reason=spam"))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		c.Request.Header.Set("Accept", "text/html")
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		handler.ReportURL(c)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Contains(t, w.Body.String(), "Report not sent")
+	})
+
+	t.Run("Admin token", func(t *testing.T) {
+		mockService := newMockURLService()
+		mockService.On("ListReports", "").Return([]models.Report{{ID: 7, ShortID: "abc123"}}, nil)
+
+		router := gin.New()
+		handler := NewURLHandler(mockService, URLHandlerConfig{AdminToken: "s3cret"})
+		router.GET("/admin/reports", handler.RequireAdmin, handler.ListReports)
+		unconfigured := NewURLHandler(mockService, URLHandlerConfig{})
+		router.GET("/closed/reports", unconfigured.RequireAdmin, unconfigured.ListReports)
+
+		tests := []struct {
+			path   string
+			auth   string
+			status int
+		}{
+			{"/admin/reports", "", http.StatusUnauthorized},
+			{"/admin/reports", "Bearer wrong", http.StatusUnauthorized},
+			{"/admin/reports", "Bearer s3cret", http.StatusOK},
+			{"/closed/reports", "Bearer s3cret", http.StatusServiceUnavailable},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code, "%s with %q", tt.path, tt.auth)
+		}
+		mockService.AssertNumberOfCalls(t, "ListReports", 1)
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		mockService := newMockURLService()
+		mockService.On("ResolveReport", uint(7), "ban", "admin", "repeat offender").
+			Return(&models.Report{ID: 7, Status: models.ReportStatusResolved, Resolution: "ban"}, nil)
+		mockService.On("ResolveReport", uint(8), "ban", "admin", "").Return(nil, services.ErrNoOwner)
+		mockService.On("ResolveReport", uint(9), "shrug", "admin", "").Return(nil, services.ErrInvalidAction)
+		handler := NewURLHandler(mockService, URLHandlerConfig{AdminToken: "s3cret"})
+
+		tests := []struct {
+			id     string
+			body   string
+			status int
+		}{
+			{"7", `{"action":"ban","note":"repeat offender"}`, http.StatusOK},
+			{"8", `{"action":"ban"}`, http.StatusConflict},
+			{"9", `{"action":"shrug"}`, http.StatusBadRequest},
+			{"x", `{"action":"ban"}`, http.StatusBadRequest},
+			{"7", `{}`, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = gin.Params{{Key: "id", Value: tt.id}}
+			c.Request = httptest.NewRequest(http.MethodPost, "/admin/reports/"+tt.id+"/resolve", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			handler.ResolveReport(c)
+			assert.Equal(t, tt.status, w.Code, "report %s with %s", tt.id, tt.body)
+		}
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestAdminConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	handler := NewURLHandler(newMockURLService(), URLHandlerConfig{
+		AdminToken: "s3cret",
+		Settings: func() interface{} {
+			return map[string]interface{}{"admin_token": "[REDACTED]", "base_url": "http://localhost:8080"}
+		},
+	})
+	router.GET("/admin/config", handler.RequireAdmin, handler.Config)
+	unconfigured := NewURLHandler(newMockURLService(), URLHandlerConfig{AdminToken: "s3cret"})
+	router.GET("/closed/config", unconfigured.RequireAdmin, unconfigured.Config)
+
+	request := func(path, auth string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", auth)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("/admin/config", "Bearer wrong").Code)
+	w := request("/admin/config", "Bearer s3cret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"admin_token":"[REDACTED]","base_url":"http://localhost:8080"}`, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, request("/closed/config", "Bearer s3cret").Code)
+}
+
+func TestReloadConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var reloadErr error
+	handler := NewURLHandler(newMockURLService(), URLHandlerConfig{
+		Reload: func() (interface{}, error) {
+			if reloadErr != nil {
+				return nil, reloadErr
+			}
+			return []map[string]interface{}{{"setting": "log.level", "old": "info", "new": "warn", "applied": true}}, nil
+		},
+	})
+	reload := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+		handler.ReloadConfig(c)
+		return w
+	}
+
+	w := reload()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"changes":[{"setting":"log.level","old":"info","new":"warn","applied":true}]}`, w.Body.String())
+
+	reloadErr = errors.New("invalid configuration:\ninvalid LOG_LEVEL")
+	w = reload()
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid LOG_LEVEL")
+}
+
+func TestQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	owner := services.KeyOwner("team-key")
+	resets := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	policy := ratelimit.DefaultPolicy(ratelimit.PerMinute(60))
+	mockService := newMockURLService()
+	mockService.On("LinkQuota", owner).Return(services.QuotaUsage{Limit: 100, Used: 40, Remaining: 60, ResetsAt: resets}, nil)
+	mockService.On("CreateShortURL", "https://example.com", mock.Anything).Return(nil, services.ErrQuotaExceeded)
+	handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080", RatePolicy: ratelimit.NewPolicySource(policy)})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/quota", nil)
+	c.Request.Header.Set("X-API-Key", "team-key")
+	handler.Quota(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, owner, response["owner"])
+	assert.Equal(t, map[string]interface{}{
+		"limit": 100.0, "used": 40.0, "remaining": 60.0, "resets_at": "2024-07-01T00:00:00Z",
+	}, response["links"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"route": "*", "rate": 60.0, "period": "1m0s", "burst": 60.0},
+	}, response["rate_limits"])
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(`{"url":"https://example.com"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("X-API-Key", "team-key")
+	handler.ShortenURL(c)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "quota")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestShortenBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(handler *URLHandler, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/shorten/batch", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		handler.ShortenBatch(c)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	t.Run("Best effort", func(t *testing.T) {
+		mockService := newMockURLService()
+		mockService.On("CreateShortURLs", mock.MatchedBy(func(reqs []services.LinkRequest) bool {
+			return len(reqs) == 2 && reqs[0].Options.Alias == "spring" && reqs[1].Options.Alias == "taken"
+		}), false).Return([]services.LinkResult{
+			{URL: &models.URL{ShortID: "spring", LongURL: "https://example.com/a", Tags: []string{"cms"}}},
+			{Err: services.ErrAliasTaken},
+		}, nil)
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w, response := request(handler, `{"items": [
+			{"url": "https://example.com/a", "alias": "spring", "tags": ["cms"]},
+			{"url": "not-a-url"},
+			{"url": "https://example.com/c", "alias": "taken", "expires_in": "24h"}
+		]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, float64(1), response["created"])
+		assert.Equal(t, float64(2), response["failed"])
+		results := response["results"].([]interface{})
+		assert.Len(t, results, 3)
+
+		first := results[0].(map[string]interface{})
+		assert.Equal(t, "created", first["status"])
+		assert.Equal(t, "http://localhost:8080/spring", first["short_url"])
+		assert.Equal(t, []interface{}{"cms"}, first["tags"])
+
+		second := results[1].(map[string]interface{})
+		assert.Equal(t, "error", second["status"])
+		assert.Equal(t, "invalid_url", second["code"])
+		assert.Equal(t, float64(1), second["index"])
+
+		third := results[2].(map[string]interface{})
+		assert.Equal(t, "alias_taken", third["code"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Atomic with invalid item", func(t *testing.T) {
+		mockService := newMockURLService()
+		handler := NewURLHandler(mockService, URLHandlerConfig{BaseURL: "http://localhost:8080"})
+
+		w, response := request(handler, `{"atomic": true, "items": [
+			{"url": "https://example.com/a"},
+			{"url": "https://example.com/b", "expires_in": "soon"}
+		]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, float64(0), response["created"])
+		results := response["results"].([]interface{})
+		assert.Equal(t, "aborted", results[0].(map[string]interface{})["code"])
+		assert.Equal(t, "invalid_expiry", results[1].(map[string]interface{})["code"])
+		mockService.AssertNotCalled(t, "CreateShortURLs", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Too many items", func(t *testing.T) {
+		handler := NewURLHandler(newMockURLService(), URLHandlerConfig{BaseURL: "http://localhost:8080", MaxBatchItems: 1})
+
+		w, _ := request(handler, `{"items": [{"url": "https://example.com/a"}, {"url": "https://example.com/b"}]}`)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
+
+func TestRedirectOutcome(t *testing.T) {
+	tests := map[error]string{
+		services.ErrURLNotFound:                           metrics.RedirectMiss,
+		fmt.Errorf("wrapped: %w", services.ErrURLExpired): metrics.RedirectExpired,
+		services.ErrURLPaused:                             metrics.RedirectBlocked,
+		services.ErrURLDisabled:                           metrics.RedirectBlocked,
+		services.ErrURLGeoBlocked:                         metrics.RedirectBlocked,
+		services.ErrPasswordRequired:                      metrics.RedirectLocked,
+		services.ErrURLNotYetActive:                       metrics.RedirectScheduled,
+		errors.New("database is locked"):                  metrics.RedirectError,
+	}
+	for err, want := range tests {
+		assert.Equal(t, want, redirectOutcome(err), err.Error())
+	}
+}
\ No newline at end of file
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/urlshortener/src/logger"
+)
+
+// readinessTimeout bounds how long a readiness check may take
+const readinessTimeout = 2 * time.Second
+
+// errDraining is reported by /readyz once the server is shutting down
+var errDraining = errors.New("server is shutting down")
+
+// ReadinessCheck reports whether a dependency can serve requests, returning
+// an error when it cannot
+type ReadinessCheck func(ctx context.Context) error
+
+// readinessCheck is a named ReadinessCheck
+type readinessCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+// ComponentStatus is the result of one readiness check
+type ComponentStatus struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AddReadinessCheck makes /readyz report not ready while check fails. Checks
+// run concurrently and each is given two seconds.
+func (s *Server) AddReadinessCheck(name string, check ReadinessCheck) {
+	s.checks = append(s.checks, readinessCheck{name: name, check: check})
+}
+
+// Drain makes /readyz report not ready and waits for delay, giving load
+// balancers time to stop sending requests before the server shuts down
+func (s *Server) Drain(delay time.Duration) {
+	if s.draining.Swap(true) || delay <= 0 {
+		return
+	}
+	logger.LogInfo("Draining before shutdown", map[string]interface{}{"delay": delay.String()})
+	time.Sleep(delay)
+}
+
+// livez reports that the process is up. It checks no dependencies, so a
+// failing database does not get the process restarted.
+func (s *Server) livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "alive",
+		"time":   time.Now().Format(time.RFC3339),
+	})
+}
+
+// readyz runs the readiness checks, answering 503 Service Unavailable if any
+// fails or the server is draining
+func (s *Server) readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	components := make(map[string]ComponentStatus, len(s.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, rc := range s.checks {
+		wg.Add(1)
+		go func(rc readinessCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := rc.check(ctx)
+			status := ComponentStatus{Status: "up", Latency: time.Since(start).String()}
+			if err != nil {
+				status.Status = "down"
+				status.Error = err.Error()
+			}
+			mu.Lock()
+			components[rc.name] = status
+			mu.Unlock()
+		}(rc)
+	}
+	wg.Wait()
+
+	ready := !s.draining.Load()
+	for _, status := range components {
+		if status.Status != "up" {
+			ready = false
+		}
+	}
+
+	body := gin.H{
+		"status":     "ready",
+		"time":       time.Now().Format(time.RFC3339),
+		"components": components,
+	}
+	code := http.StatusOK
+	if !ready {
+		body["status"] = "not_ready"
+		code = http.StatusServiceUnavailable
+	}
+	if s.draining.Load() {
+		body["error"] = errDraining.Error()
+	}
+	c.JSON(code, body)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthChecks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewServer(ServerConfig{StaticDir: t.TempDir()})
+	var redisErr error
+	server.AddReadinessCheck("sqlite", func(context.Context) error { return nil })
+	server.AddReadinessCheck("redis", func(context.Context) error { return redisErr })
+	server.RegisterRoutes(nil, nil)
+
+	type response struct {
+		Status     string                     `json:"status"`
+		Components map[string]ComponentStatus `json:"components"`
+	}
+	get := func(path string) (int, response) {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	code, body := get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body.Status)
+	assert.Equal(t, "up", body.Components["sqlite"].Status)
+	assert.NotEmpty(t, body.Components["sqlite"].Latency)
+
+	// A failing dependency makes the server not ready but still alive
+	redisErr = assert.AnError
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", body.Status)
+	assert.Equal(t, ComponentStatus{Status: "down", Latency: body.Components["redis"].Latency, Error: assert.AnError.Error()}, body.Components["redis"])
+	assert.Equal(t, "up", body.Components["sqlite"].Status)
+	code, _ = get("/health")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	code, body = get("/livez")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "alive", body.Status)
+
+	// Draining servers are not ready even when every dependency is up
+	redisErr = nil
+	server.Drain(time.Millisecond)
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", body.Status)
+	code, _ = get("/livez")
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -1,104 +1,167 @@
 package api
 
 import (
-	"encoding/json"
-	"log"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/urlshortener/src/logger"
+	"github.com/yourusername/urlshortener/src/metrics"
+	"github.com/yourusername/urlshortener/src/ratelimit"
+	"github.com/yourusername/urlshortener/src/services"
+	"go.uber.org/zap"
 )
 
-// responseWriter is a custom response writer that captures the status code
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
+// RateLimit limits requests as the policy in force says, using limiter to
+// count them. Requests with an API key listed in the policy are limited per
+// key, and all others per client IP address; allowlisted addresses are not
+// limited. The client address only comes from X-Forwarded-For when the
+// router trusts the proxy that sent it (see ServerConfig.TrustedProxies), so
+// clients cannot claim an allowlisted address. Limited responses carry the X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset headers, and refused requests
+// get 429 Too Many Requests with Retry-After. Requests are let through when the limiter fails.
+func RateLimit(limiter ratelimit.Limiter, policies *ratelimit.PolicySource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := policies.Load()
+		ip := c.ClientIP()
+		if policy.Allowlisted(ip) {
+			c.Next()
+			return
+		}
+		subject := policy.Subject(services.KeyOwner(c.GetHeader("X-API-Key")), ip)
+		rule, ok := policy.Rule(subject.Plan, c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), subject.Key+"|"+rule.Route, rule.Limit())
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Error("Rate limiter failed",
+				zap.Error(err),
+				zap.String("subject", subject.Key))
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+		if !res.Allowed {
+			metrics.RateLimitRejections.WithLabelValues(rule.Route, subject.Plan).Inc()
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
 }
 
-// WriteHeader captures the status code before writing it
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+// unmatchedRoute labels the metrics of requests that matched no route, so
+// that made-up paths do not each get their own series
+const unmatchedRoute = "unmatched"
+
+// Metrics counts requests and observes their duration by method, route and
+// status code
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		labels := []string{c.Request.Method, route, strconv.Itoa(c.Writer.Status())}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	}
 }
 
-// RateLimiter implements a simple rate limiting mechanism
-type RateLimiter struct {
-	requests map[string][]time.Time
-	mu       sync.Mutex
-	limit    int
-	window   time.Duration
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
 }
 
-// NewRateLimiter creates a new rate limiter
-func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
-	return &RateLimiter{
-		requests: make(map[string][]time.Time),
-		limit:    limit,
-		window:   window,
+// RequestIDHeader carries the ID that ties a request to its log lines
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds request IDs given by clients
+const maxRequestIDLen = 128
+
+// RequestID gives each request an ID, keeping the one in the X-Request-ID
+// header when it is usable and generating one otherwise. The ID is sent
+// back in X-Request-ID and carried in the request's context, where
+// logger.FromContext finds it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+		c.Next()
 	}
 }
 
-// rateLimitMiddleware implements rate limiting
-func rateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := r.RemoteAddr
-			now := time.Now()
-
-			limiter.mu.Lock()
-			defer limiter.mu.Unlock()
-
-			// Clean up old requests
-			if requests, exists := limiter.requests[ip]; exists {
-				var validRequests []time.Time
-				for _, reqTime := range requests {
-					if now.Sub(reqTime) < limiter.window {
-						validRequests = append(validRequests, reqTime)
-					}
-				}
-				limiter.requests[ip] = validRequests
-
-				// Check if rate limit exceeded
-				if len(validRequests) >= limiter.limit {
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusTooManyRequests)
-					json.NewEncoder(w).Encode(map[string]string{
-						"error": "Rate limit exceeded",
-					})
-					return
-				}
-			}
-
-			// Add new request
-			limiter.requests[ip] = append(limiter.requests[ip], now)
-
-			next.ServeHTTP(w, r)
-		})
+// validRequestID reports whether a client's request ID is safe to log and
+// echo: up to 128 letters, digits and -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.', ch == ':':
+		default:
+			return false
+		}
 	}
+	return true
 }
 
-// loggingMiddleware logs information about each request
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// newRequestID returns a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
 
-		// Create custom response writer
-		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-
-		// Process request
-		next.ServeHTTP(rw, r)
-
-		// Log request details
-		duration := time.Since(start)
-		log.Printf(
-			"method=%s path=%s status=%d duration=%s ip=%s user_agent=%s",
-			r.Method,
-			r.URL.Path,
-			rw.statusCode,
-			duration,
-			r.RemoteAddr,
-			r.UserAgent(),
-		)
-	})
+// AccessLog logs each request once it has been handled. Server errors are
+// logged at error level and everything else at info level.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("duration", time.Since(start)),
+			zap.Int("bytes", c.Writer.Size()),
+			zap.String("client_ip", c.ClientIP()),
+			zap.String("user_agent", c.Request.UserAgent()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate); len(errs) > 0 {
+			fields = append(fields, zap.String("errors", errs.String()))
+		}
+		log := logger.FromContext(c.Request.Context())
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			log.Error("Request handled", fields...)
+			return
+		}
+		log.Info("Request handled", fields...)
+	}
 }
 
 // ErrorResponse represents an error response
@@ -107,25 +170,16 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
-// recoveryMiddleware recovers from panics and returns a 500 error
-func recoveryMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("panic: %v", err)
-
-				// Set headers
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-
-				// Write error response
-				json.NewEncoder(w).Encode(ErrorResponse{
-					Error:   "Internal Server Error",
-					Message: "An unexpected error occurred",
-				})
-			}
-		}()
-
-		next.ServeHTTP(w, r)
+// Recovery turns panics into 500 Internal Server Error responses, logging
+// them with the request ID
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err interface{}) {
+		logger.FromContext(c.Request.Context()).Error("Recovered from panic",
+			zap.Any("panic", err),
+			zap.Stack("stack"))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "An unexpected error occurred",
+		})
 	})
-} 
\ No newline at end of file
+}
\ No newline at end of file
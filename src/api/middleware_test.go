@@ -0,0 +1,166 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/urlshortener/src/logger"
+	"github.com/yourusername/urlshortener/src/metrics"
+	"github.com/yourusername/urlshortener/src/ratelimit"
+	"github.com/yourusername/urlshortener/src/services"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RateLimit(ratelimit.NewMemoryLimiter(), ratelimit.NewPolicySource(ratelimit.DefaultPolicy(ratelimit.Limit{Rate: 2, Period: time.Minute}))))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("192.0.2.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("X-RateLimit-Reset"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	request("192.0.2.1")
+	w = request("192.0.2.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	// Each IP address has its own limit
+	assert.Equal(t, http.StatusOK, request("192.0.2.2").Code)
+}
+
+func TestRateLimitPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+allowlist: [10.0.0.0/8]
+default_plan: free
+plans:
+  free:
+    limits: [{route: POST /shorten, rate: 1, period: 1m}]
+  internal:
+    limits: [{route: POST /shorten, rate: 100, period: 1s}]
+keys:
+  `+services.KeyOwner("internal-key")+`: {plan: internal}
+`), 0644))
+	policy, err := ratelimit.LoadPolicy(path)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(RateLimit(ratelimit.NewMemoryLimiter(), ratelimit.NewPolicySource(policy)))
+	router.POST("/shorten", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	router.GET("/links", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(method, path, ip, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, request("POST", "/shorten", "192.0.2.1", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("POST", "/shorten", "192.0.2.1", "").Code)
+	// Made-up keys do not get a fresh limit
+	assert.Equal(t, http.StatusTooManyRequests, request("POST", "/shorten", "192.0.2.1", "made-up").Code)
+
+	// Listed keys have their plan's limit, wherever they come from
+	for i := 0; i < 5; i++ {
+		w := request("POST", "/shorten", "192.0.2.1", "internal-key")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "100", w.Header().Get("X-RateLimit-Limit"))
+	}
+
+	// Routes without a rule and allowlisted addresses are not limited
+	w := request("GET", "/links", "192.0.2.1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusCreated, request("POST", "/shorten", "10.1.2.3", "").Code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	router.GET("/links/:shortID", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	counter := func(route, status string) float64 {
+		return testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", route, status))
+	}
+	before, beforeUnmatched := counter("/links/:shortID", "200"), counter(unmatchedRoute, "404")
+
+	for _, path := range []string{"/links/abc", "/links/def", "/nope", "/also/nope"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests are labelled with their route rather than their path
+	assert.Equal(t, before+2, counter("/links/:shortID", "200"))
+	assert.Equal(t, beforeUnmatched+2, counter(unmatchedRoute, "404"))
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Recovery())
+	var seen string
+	router.GET("/ping", func(c *gin.Context) {
+		seen = logger.RequestID(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	request := func(path, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A usable ID from the client is kept
+	w := request("/ping", "client-id.42")
+	assert.Equal(t, "client-id.42", w.Header().Get(RequestIDHeader))
+	assert.Equal(t, "client-id.42", seen)
+
+	// Missing and unsafe IDs are replaced
+	for _, id := range []string{"", "bad id\n", strings.Repeat("a", maxRequestIDLen+1)} {
+		w = request("/ping", id)
+		generated := w.Header().Get(RequestIDHeader)
+		assert.Len(t, generated, 32)
+		assert.Equal(t, generated, seen)
+	}
+
+	// Panics become 500s that still carry the ID
+	w = request("/panic", "panicky")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "panicky", w.Header().Get(RequestIDHeader))
+	assert.JSONEq(t, `{"error":"Internal Server Error","message":"An unexpected error occurred"}`, w.Body.String())
+}
@@ -5,28 +5,78 @@ import (
 	"net/http"
 	"time"
 	"path/filepath"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yourusername/urlshortener/src/api/handlers"
 	"github.com/yourusername/urlshortener/src/logger"
+	"github.com/yourusername/urlshortener/src/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// ServerConfig holds the HTTP server settings. Zero timeouts mean none.
+type ServerConfig struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// StaticDir holds the web UI served at / and /static
+	StaticDir string
+	// ServiceName names the service in request spans
+	ServiceName string
+	// TrustedProxies lists the proxies, as addresses or CIDR ranges, whose
+	// X-Forwarded-For header gives the client address. Nil trusts none.
+	TrustedProxies []string
+	Features    Features
+}
+
+// Features switches optional routes on
+type Features struct {
+	Analytics bool
+	QRCodes   bool
+	Reports   bool
+	// Metrics exposes Prometheus metrics at /metrics
+	Metrics bool
+	// Tracing starts a span for each request, continuing the trace of a W3C
+	// traceparent header
+	Tracing bool
+}
+
 // Server represents the API server
 type Server struct {
-	router *gin.Engine
-	port   string
-	server *http.Server
+	router    *gin.Engine
+	config    ServerConfig
+	server    *http.Server
+	rateLimit gin.HandlerFunc
+	checks    []readinessCheck
+	draining  atomic.Bool
 }
 
 // NewServer creates a new server instance
-func NewServer() *Server {
-	router := gin.Default()
+func NewServer(cfg ServerConfig) *Server {
+	router := gin.New()
+	// Client addresses are used for rate limits, so they are only taken
+	// from headers set by known proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.LogError(err, "Invalid trusted proxies, trusting none", nil)
+		_ = router.SetTrustedProxies(nil)
+	}
+	router.Use(RequestID())
+	if cfg.Features.Tracing {
+		router.Use(otelgin.Middleware(cfg.ServiceName))
+	}
+	router.Use(AccessLog(), Recovery())
+	if cfg.Features.Metrics {
+		router.Use(Metrics())
+	}
 	
 	// Add CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -36,8 +86,8 @@ func NewServer() *Server {
 		c.Next()
 	})
 
-	// Serve static files with absolute path
-	staticPath := filepath.Join("/app", "static")
+	// Serve static files
+	staticPath := cfg.StaticDir
 	router.Static("/static", staticPath)
 	
 	// Serve index.html for root path
@@ -47,47 +97,105 @@ func NewServer() *Server {
 
 	return &Server{
 		router: router,
-		port:   "8080",
+		config: cfg,
 	}
 }
 
+// UseRateLimit limits every route registered afterwards except the health
+// checks and metrics with the given middleware, e.g. RateLimit
+func (s *Server) UseRateLimit(middleware gin.HandlerFunc) {
+	s.rateLimit = middleware
+}
+
 // RegisterRoutes registers all API routes
 func (s *Server) RegisterRoutes(urlHandler *handlers.URLHandler, analyticsHandler *handlers.AnalyticsHandler) {
-	// Health check
-	s.router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "healthy",
-			"time":   time.Now().Format(time.RFC3339),
-		})
-	})
+	// Health checks. /health is kept for existing monitors and reports
+	// readiness.
+	s.router.GET("/livez", s.livez)
+	s.router.GET("/readyz", s.readyz)
+	s.router.GET("/health", s.readyz)
+
+	if s.config.Features.Metrics {
+		s.router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+
+	routes := s.router.Group("/")
+	if s.rateLimit != nil {
+		routes.Use(s.rateLimit)
+	}
 
 	// URL routes
-	s.router.POST("/shorten", urlHandler.ShortenURL)
-	s.router.GET("/:shortID", urlHandler.RedirectToLongURL)
+	routes.POST("/shorten", urlHandler.ShortenURL)
+	routes.POST("/shorten/batch", urlHandler.ShortenBatch)
+	routes.GET("/:shortID", urlHandler.RedirectToLongURL)
+	routes.POST("/:shortID/unlock", urlHandler.UnlockURL)
+	routes.GET("/expand", urlHandler.ExpandURL)
+	if s.config.Features.QRCodes {
+		routes.GET("/:shortID/qr", urlHandler.QRCode)
+		routes.POST("/links/qr", urlHandler.BulkQRCodes)
+	}
+	if s.config.Features.Reports {
+		routes.POST("/report/:shortID", urlHandler.ReportURL)
+	}
+
+	routes.GET("/quota", urlHandler.Quota)
+
+	// Link management routes
+	routes.GET("/links", urlHandler.ListURLs)
+	routes.GET("/links/trash", urlHandler.ListTrash)
+	routes.PATCH("/links/:shortID", urlHandler.UpdateURL)
+	routes.DELETE("/links/:shortID", urlHandler.DeleteURL)
+	routes.POST("/links/:shortID/pause", urlHandler.PauseURL)
+	routes.POST("/links/:shortID/resume", urlHandler.ResumeURL)
+	routes.POST("/links/:shortID/restore", urlHandler.RestoreURL)
+
+	// Moderation routes
+	routes.GET("/links/:shortID/audit", urlHandler.RequireAdmin, urlHandler.AuditLog)
+	routes.GET("/links/held", urlHandler.RequireAdmin, urlHandler.ListHeldURLs)
+	routes.POST("/links/:shortID/approve", urlHandler.RequireAdmin, urlHandler.ApproveURL)
+
+	// Admin routes
+	registerAdminRoutes(routes.Group("/admin", urlHandler.RequireAdmin), urlHandler, s.config.Features)
 
 	// Analytics routes
-	s.router.GET("/analytics", analyticsHandler.GetAnalytics)
-	s.router.POST("/analytics/click", analyticsHandler.RecordClick)
+	if s.config.Features.Analytics {
+		routes.GET("/analytics", analyticsHandler.GetAnalytics)
+		routes.POST("/analytics/click", analyticsHandler.RecordClick)
+	}
+}
+
+// registerAdminRoutes registers the admin API on group
+func registerAdminRoutes(admin *gin.RouterGroup, urlHandler *handlers.URLHandler, features Features) {
+	admin.GET("/config", urlHandler.Config)
+	admin.POST("/reload", urlHandler.ReloadConfig)
+	if features.Reports {
+		admin.GET("/reports", urlHandler.ListReports)
+		admin.POST("/reports/:id/triage", urlHandler.TriageReport)
+		admin.POST("/reports/:id/resolve", urlHandler.ResolveReport)
+	}
 }
 
 // Start starts the server
 func (s *Server) Start() error {
 	// Create HTTP server with timeouts
 	s.server = &http.Server{
-		Addr:         ":" + s.port,
-		Handler:      s.router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              s.config.Addr,
+		Handler:           s.router,
+		ReadTimeout:       s.config.ReadTimeout,
+		ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		WriteTimeout:      s.config.WriteTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
 	}
 
 	// Start server
-	logger.LogInfo("Server starting on port "+s.port, nil)
+	logger.LogInfo("Server starting on "+s.config.Addr, nil)
 	return s.server.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the server
+// Shutdown gracefully shuts down the server. /readyz reports not ready
+// from then on.
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.draining.Store(true)
 	if s.server != nil {
 		return s.server.Shutdown(ctx)
 	}
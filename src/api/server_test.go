@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/urlshortener/src/api/handlers"
+	"github.com/yourusername/urlshortener/src/ratelimit"
+)
+
+func TestAdminOnlyRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewServer(ServerConfig{StaticDir: t.TempDir()})
+	server.RegisterRoutes(handlers.NewURLHandler(nil, handlers.URLHandlerConfig{AdminToken: "secret"}), nil)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/links/held"},
+		{http.MethodPost, "/links/abc/approve"},
+		{http.MethodGet, "/links/abc/audit"},
+	} {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(route.method, route.path, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "%s %s", route.method, route.path)
+	}
+}
+
+func TestForwardedForNeedsTrustedProxy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newServer := func(proxies []string) *Server {
+		server := NewServer(ServerConfig{StaticDir: t.TempDir(), TrustedProxies: proxies})
+		server.UseRateLimit(RateLimit(ratelimit.NewMemoryLimiter(), ratelimit.NewPolicySource(
+			ratelimit.DefaultPolicy(ratelimit.Limit{Rate: 1, Period: time.Minute}))))
+		server.router.GET("/ping", server.rateLimit, func(c *gin.Context) { c.Status(http.StatusOK) })
+		return server
+	}
+	request := func(server *Server, forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = "203.0.113.5:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		server.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Without trusted proxies a new X-Forwarded-For does not reset the limit
+	server := newServer(nil)
+	assert.Equal(t, http.StatusOK, request(server, "192.0.2.1"))
+	assert.Equal(t, http.StatusTooManyRequests, request(server, "192.0.2.2"))
+
+	// Behind a trusted proxy each forwarded client has its own limit
+	server = newServer([]string{"203.0.113.0/24"})
+	assert.Equal(t, http.StatusOK, request(server, "192.0.2.1"))
+	assert.Equal(t, http.StatusOK, request(server, "192.0.2.2"))
+	assert.Equal(t, http.StatusTooManyRequests, request(server, "192.0.2.2"))
+}
+
+func TestAllowlistIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+allowlist: [10.0.0.0/8]
+default_plan: free
+plans:
+  free:
+    limits: [{route: GET /ping, rate: 1, period: 1m}]
+`), 0644))
+	policy, err := ratelimit.LoadPolicy(path)
+	require.NoError(t, err)
+
+	newServer := func(proxies []string) *Server {
+		server := NewServer(ServerConfig{StaticDir: t.TempDir(), TrustedProxies: proxies})
+		server.UseRateLimit(RateLimit(ratelimit.NewMemoryLimiter(), ratelimit.NewPolicySource(policy)))
+		server.router.GET("/ping", server.rateLimit, func(c *gin.Context) { c.Status(http.StatusOK) })
+		return server
+	}
+	request := func(server *Server) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = "203.0.113.5:1234"
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		req.Header.Set("X-Real-IP", "10.0.0.1")
+		server.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Claiming an allowlisted address does not lift the limit
+	server := newServer(nil)
+	assert.Equal(t, http.StatusOK, request(server))
+	assert.Equal(t, http.StatusTooManyRequests, request(server))
+
+	// Unless a trusted proxy vouches for it
+	server = newServer([]string{"203.0.113.5"})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, request(server))
+	}
+}
@@ -0,0 +1,360 @@
+package app
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yourusername/urlshortener/config"
+	"github.com/yourusername/urlshortener/src/api"
+	"github.com/yourusername/urlshortener/src/api/handlers"
+	"github.com/yourusername/urlshortener/src/logger"
+	"github.com/yourusername/urlshortener/src/metrics"
+	"github.com/yourusername/urlshortener/src/models"
+	"github.com/yourusername/urlshortener/src/ratelimit"
+	"github.com/yourusername/urlshortener/src/services"
+	"github.com/yourusername/urlshortener/src/storage"
+	"github.com/yourusername/urlshortener/src/tracing"
+)
+
+// Run starts the URL shortener and serves until SIGINT or SIGTERM, then
+// shuts down gracefully. Errors are logged before they are returned.
+func Run() error {
+	// Initialize logger
+	if err := logger.Init(true); err != nil {
+		log.Printf("Failed to initialize logger: %v", err)
+		return err
+	}
+	defer logger.Sync()
+
+	zlog := logger.Get()
+	zlog.Info("Starting URL shortener service")
+
+	// Load config
+	configStore, err := config.NewStore(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		logger.LogError(err, "Failed to load config", nil)
+		return err
+	}
+	dbConfig := configStore.Config()
+	if err := logger.Configure(dbConfig.Log.Format, dbConfig.Log.Level); err != nil {
+		logger.LogError(err, "Failed to configure logger", nil)
+		return err
+	}
+	zlog = logger.Get()
+
+	// Set up tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    dbConfig.Tracing.Exporter,
+		Endpoint:    dbConfig.Tracing.Endpoint,
+		ServiceName: dbConfig.Tracing.ServiceName,
+		SampleRatio: dbConfig.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.LogError(err, "Failed to set up tracing", nil)
+		return err
+	}
+
+	// Initialize database
+	db, err := storage.NewDatabase(&dbConfig.Database)
+	if err != nil {
+		logger.LogError(err, "Failed to initialize database", nil)
+		return err
+	}
+
+	// Run migrations
+	if err := storage.RunMigrations(db.SQLite); err != nil {
+		logger.LogError(err, "Failed to run migrations", nil)
+		return err
+	}
+
+	// Export the connection pool statistics
+	if sqlDB, err := db.SQLite.DB(); err == nil {
+		if err := metrics.RegisterDB(sqlDB, "sqlite"); err != nil {
+			logger.LogError(err, "Failed to register database metrics", nil)
+		}
+	}
+
+	// Initialize GeoIP service
+	var geoService *services.GeoService
+	if dbConfig.Features.GeoIP {
+		geoService, err = services.NewGeoService(dbConfig.GeoIP.CityDB)
+		if err != nil {
+			logger.LogError(err, "Failed to initialize geo service", nil)
+			logger.LogInfo("Continuing without geo location features", nil)
+		} else {
+			defer geoService.Close()
+			logger.LogInfo("GeoIP service initialized successfully", nil)
+		}
+	}
+
+	// Background jobs run until the server shuts down
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// Load the blocklist, reloading it whenever the file changes
+	blocklist := &services.Blocklist{}
+	if dbConfig.Risk.BlocklistFile != "" {
+		loaded, err := services.LoadBlocklist(dbConfig.Risk.BlocklistFile)
+		if err != nil {
+			logger.LogError(err, "Failed to load blocklist", nil)
+			return err
+		}
+		blocklist.Replace(loaded)
+	}
+	blocklist.Watch(jobCtx, dbConfig.Risk.BlocklistReload)
+
+	// Set up the external reputation check
+	var reputation services.ReputationChecker
+	if dbConfig.Reputation.SafeBrowsingKey != "" {
+		reputation = services.NewCachedReputationChecker(services.NewSafeBrowsingClient(services.SafeBrowsingConfig{
+			APIKey:   dbConfig.Reputation.SafeBrowsingKey,
+			Endpoint: dbConfig.Reputation.SafeBrowsingEndpoint,
+		}), dbConfig.Reputation.CacheTTL)
+	}
+
+	// Initialize services
+	// Load the rate limit and quota policy
+	ratePolicy, err := ratelimit.NewPolicy(dbConfig.RateLimit.PolicyFile, fallbackLimit(dbConfig.RateLimit))
+	if err != nil {
+		logger.LogError(err, "Failed to load rate limit policy", nil)
+		return err
+	}
+	ratePolicies := ratelimit.NewPolicySource(ratePolicy)
+
+	// Share request limits and unlock and report attempts between instances
+	// through Redis when it is available
+	var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+	if db.Redis != nil {
+		limiter = ratelimit.NewRedisLimiter(db.Redis, "ratelimit:")
+	}
+
+	urlService := services.NewURLService(db.SQLite, services.URLServiceConfig{
+		Expiry: services.ExpiryPolicy{
+			DefaultTTL: dbConfig.Expiry.DefaultTTL,
+			MaxTTL:     dbConfig.Expiry.MaxTTL,
+		},
+		Unlock: services.UnlockPolicy{
+			Secret:        dbConfig.Unlock.Secret,
+			CookieTTL:     dbConfig.Unlock.CookieTTL,
+			MaxFailures:   dbConfig.Unlock.MaxFailures,
+			FailureWindow: dbConfig.Unlock.FailureWindow,
+		},
+		Canonical: services.CanonicalOptions{
+			SortQuery:      dbConfig.Canonical.SortQuery,
+			StripTracking:  dbConfig.Canonical.StripTracking,
+			TrackingParams: dbConfig.Canonical.TrackingParams,
+		},
+		Destination: services.DestinationPolicy{
+			AllowedSchemes:  dbConfig.Destination.AllowedSchemes,
+			BlockedHosts:    dbConfig.Destination.BlockedHosts,
+			BlockedNetworks: dbConfig.Destination.BlockedNetworks,
+			MaxLength:       dbConfig.Destination.MaxLength,
+			OwnHosts:        dbConfig.Destination.OwnHosts,
+			ResolveHosts:    dbConfig.Destination.ResolveHosts,
+		},
+		Risk: services.RiskPolicy{
+			Blocklist:     blocklist,
+			MaxSubdomains: dbConfig.Risk.MaxSubdomains,
+			HoldScore:     dbConfig.Risk.HoldScore,
+			RejectScore:   dbConfig.Risk.RejectScore,
+		},
+		Reputation: reputation,
+		Report: services.ReportPolicy{
+			MaxPerIP: dbConfig.Report.MaxPerIP,
+			Window:   dbConfig.Report.Window,
+		},
+		Quota:    ratePolicies,
+		Attempts: limiter,
+	})
+	analyticsService := services.NewAnalyticsService(db.SQLite, geoService)
+
+	// Record clicks in the background so redirects do not wait for them
+	clickQueue := services.NewClickQueue(analyticsService, dbConfig.ClickQueueSize)
+	clickQueue.Start(jobCtx)
+
+	// Start purging links that have been in the trash past the grace period
+	urlService.StartPurgeJob(jobCtx, dbConfig.Trash.PurgeInterval, dbConfig.Trash.GracePeriod)
+
+	// Rescan link destinations, disabling those that turned unsafe
+	if reputation != nil {
+		urlService.StartRescanJob(jobCtx, dbConfig.Reputation.RescanInterval)
+	}
+
+	// Load the pages served in place of redirects
+	pages, err := handlers.LoadPages(dbConfig.TemplateDir)
+	if err != nil {
+		logger.LogError(err, "Failed to load page templates", nil)
+		return err
+	}
+
+	// Build the default redirect profile
+	defaultRedirect := models.RedirectProfile{
+		StatusCode:     dbConfig.Redirect.StatusCode,
+		CacheMaxAge:    &dbConfig.Redirect.CacheMaxAge,
+		ReferrerPolicy: dbConfig.Redirect.ReferrerPolicy,
+		NoIndex:        &dbConfig.Redirect.NoIndex,
+	}
+	if err := defaultRedirect.Validate(); err != nil {
+		logger.LogError(err, "Invalid default redirect profile", nil)
+		return err
+	}
+
+	// Reload the log level, blocklist and rate limits on SIGHUP and
+	// POST /admin/reload. Other settings need a restart.
+	configStore.OnReload(func(cfg *config.Config) (func(), error) {
+		return func() { _ = logger.SetLevel(cfg.Log.Level) }, nil
+	}, "log.level")
+	configStore.OnReload(func(cfg *config.Config) (func(), error) {
+		if cfg.Risk.BlocklistFile == "" {
+			return func() { blocklist.Replace(nil) }, nil
+		}
+		loaded, err := services.LoadBlocklist(cfg.Risk.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		return func() { blocklist.Replace(loaded) }, nil
+	}, "risk.blocklist_file")
+	configStore.OnReload(func(cfg *config.Config) (func(), error) {
+		policy, err := ratelimit.NewPolicy(cfg.RateLimit.PolicyFile, fallbackLimit(cfg.RateLimit))
+		if err != nil {
+			return nil, err
+		}
+		return func() { ratePolicies.Store(policy) }, nil
+	}, "rate_limit")
+	reloadConfig := func() (interface{}, error) {
+		changes, err := configStore.Reload()
+		if err != nil {
+			logger.LogError(err, "Failed to reload config, keeping the current one", nil)
+			return nil, err
+		}
+		logger.LogInfo("Reloaded config", map[string]interface{}{"changes": len(changes)})
+		return changes, nil
+	}
+
+	// Initialize handlers
+	urlHandler := handlers.NewURLHandler(urlService, handlers.URLHandlerConfig{
+		BaseURL:         dbConfig.BaseURL,
+		PrelaunchURL:    dbConfig.PrelaunchURL,
+		Pages:           pages,
+		DefaultRedirect: defaultRedirect,
+		Clicks:          clickQueue,
+		MaxBatchItems:   dbConfig.BatchMaxItems,
+		AdminToken:      dbConfig.AdminToken,
+		RatePolicy:      ratePolicies,
+		DisableReports:  !dbConfig.Features.Reports,
+		Settings:        func() interface{} { return configStore.Config().Redacted() },
+		Reload:          reloadConfig,
+		WithContext: func(ctx context.Context) handlers.URLService {
+			return urlService.WithContext(ctx)
+		},
+	})
+	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, urlService)
+
+	// Initialize server
+	server := api.NewServer(api.ServerConfig{
+		Addr:              dbConfig.Server.Addr,
+		ReadTimeout:       dbConfig.Server.ReadTimeout,
+		ReadHeaderTimeout: dbConfig.Server.ReadHeaderTimeout,
+		WriteTimeout:      dbConfig.Server.WriteTimeout,
+		IdleTimeout:       dbConfig.Server.IdleTimeout,
+		StaticDir:         dbConfig.Server.StaticDir,
+		ServiceName:       dbConfig.Tracing.ServiceName,
+		TrustedProxies:    dbConfig.Server.TrustedProxies,
+		Features: api.Features{
+			Analytics: dbConfig.Features.Analytics,
+			QRCodes:   dbConfig.Features.QRCodes,
+			Reports:   dbConfig.Features.Reports,
+			Metrics:   dbConfig.Features.Metrics,
+			Tracing:   dbConfig.Tracing.Exporter != tracing.ExporterNone,
+		},
+	})
+	server.UseRateLimit(api.RateLimit(limiter, ratePolicies))
+
+	// Report not ready while a dependency is failing
+	server.AddReadinessCheck("sqlite", db.PingSQLite)
+	if db.Redis != nil {
+		server.AddReadinessCheck("redis", db.PingRedis)
+	}
+	if geoService != nil {
+		server.AddReadinessCheck("geoip", func(context.Context) error { return geoService.Ping() })
+	}
+	server.AddReadinessCheck("click_queue", clickQueue.Check)
+	server.RegisterRoutes(urlHandler, analyticsHandler)
+
+	// Create a channel to listen for errors coming from the servers
+	serverErrors := make(chan error, 2)
+
+	// Start server in a goroutine
+	go func() {
+		serverErrors <- server.Start()
+	}()
+
+	// Serve profiling, the log level and the admin API on a local listener
+	var adminServer *api.AdminServer
+	if dbConfig.Server.AdminAddr != "" {
+		adminServer = api.NewAdminServer(dbConfig.Server.AdminAddr, api.Features{Reports: dbConfig.Features.Reports})
+		adminServer.RegisterRoutes(urlHandler)
+		go func() {
+			serverErrors <- adminServer.Start()
+		}()
+	}
+
+	// Reload the configuration on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			_, _ = reloadConfig()
+		}
+	}()
+
+	// Wait for interrupt signal or server error
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErrors:
+		logger.LogError(err, "Server error", nil)
+		return err
+	case <-quit:
+		zlog.Info("Shutting down server...")
+	}
+
+	// Stop receiving traffic before shutting down
+	server.Drain(dbConfig.Server.DrainDelay)
+
+	// Create a deadline for server shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), dbConfig.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.LogError(err, "Server forced to shutdown", nil)
+		return err
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.LogError(err, "Admin server forced to shutdown", nil)
+		}
+	}
+
+	// Record the clicks still queued
+	stopJobs()
+	<-clickQueue.Done()
+
+	// Send the spans still buffered
+	if err := shutdownTracing(ctx); err != nil {
+		logger.LogError(err, "Failed to flush traces", nil)
+	}
+
+	zlog.Info("Server exiting")
+	return nil
+}
+
+// fallbackLimit returns the rate limit for routes the policy file does not
+// cover
+func fallbackLimit(cfg config.RateLimitConfig) ratelimit.Limit {
+	return ratelimit.Limit{Rate: cfg.Requests, Period: cfg.Period, Burst: cfg.Burst}
+}
@@ -3,19 +3,18 @@ package geo
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"net"
 
 	"github.com/oschwald/geoip2-golang"
+	"github.com/yourusername/urlshortener/src/metrics"
 )
 
 type Service struct {
 	reader *geoip2.Reader
 }
 
-func NewService(dataDir string) (*Service, error) {
-	dbPath := filepath.Join(dataDir, "geoip", "GeoLite2-City.mmdb")
-	
+// NewService opens the GeoLite2 City database at dbPath
+func NewService(dbPath string) (*Service, error) {
 	// Check if database file exists
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("GeoLite2 database file not found at %s", dbPath)
@@ -38,17 +37,28 @@ func (s *Service) GetLocation(ip string) (string, error) {
 
 	parsedIP := net.ParseIP(ip)
 	if parsedIP == nil {
+		metrics.GeoIPErrors.Inc()
 		return "Unknown", fmt.Errorf("invalid IP address: %s", ip)
 	}
 
 	record, err := s.reader.City(parsedIP)
 	if err != nil {
+		metrics.GeoIPErrors.Inc()
 		return "Unknown", err
 	}
 
 	return record.Country.Names["en"], nil
 }
 
+// Ping checks that the database can still be read
+func (s *Service) Ping() error {
+	if s.reader == nil {
+		return fmt.Errorf("GeoLite2 database is not open")
+	}
+	_, err := s.reader.City(net.IPv4(127, 0, 0, 1))
+	return err
+}
+
 func (s *Service) Close() {
 	if s.reader != nil {
 		s.reader.Close()
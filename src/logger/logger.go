@@ -1,11 +1,16 @@
 package logger
 
 import (
+	"net/http"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-var log *zap.Logger
+var (
+	log   *zap.Logger
+	level zap.AtomicLevel
+)
 
 // Init initializes the logger
 func Init(debug bool) error {
@@ -23,10 +28,42 @@ func Init(debug bool) error {
 	if err != nil {
 		return err
 	}
+	level = config.Level
+
+	return nil
+}
+
+// Configure rebuilds the logger with the given format, console or json, and
+// minimum level
+func Configure(format, minLevel string) error {
+	if _, err := zapcore.ParseLevel(minLevel); err != nil {
+		return err
+	}
+	if err := Init(format != "json"); err != nil {
+		return err
+	}
+	return SetLevel(minLevel)
+}
 
+// SetLevel changes the minimum level of the logger while it is in use
+func SetLevel(minLevel string) error {
+	parsed, err := zapcore.ParseLevel(minLevel)
+	if err != nil {
+		return err
+	}
+	level.SetLevel(parsed)
 	return nil
 }
 
+// LevelHandler serves the minimum level: GET reports it and PUT changes it,
+// e.g. with {"level":"info"}. See zap.AtomicLevel.ServeHTTP.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Get()
+		level.ServeHTTP(w, r)
+	})
+}
+
 // Get returns the logger instance
 func Get() *zap.Logger {
 	if log == nil {
@@ -0,0 +1,121 @@
+// Package metrics holds the Prometheus collectors of the service and the
+// handler that exposes them
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "urlshortener"
+
+// Redirect outcomes
+const (
+	RedirectHit       = "hit"
+	RedirectMiss      = "miss"
+	RedirectExpired   = "expired"
+	RedirectBlocked   = "blocked"
+	RedirectLocked    = "locked"
+	RedirectScheduled = "scheduled"
+	RedirectError     = "error"
+)
+
+// Cache lookup results
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Registry holds every collector of the service, along with the Go runtime
+// and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts handled requests by method, route and status
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration observes how long requests take by method, route and status
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by method, route and status code.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route", "status"})
+
+	// Redirects counts visits to short links by outcome
+	Redirects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Visits to short links by outcome: hit, miss, expired, blocked, locked, scheduled or error.",
+	}, []string{"outcome"})
+
+	// ClickQueueDepth is the number of clicks waiting to be recorded
+	ClickQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "click_queue_depth",
+		Help:      "Clicks waiting to be recorded.",
+	})
+
+	// ClickDrops counts clicks dropped because the queue was full
+	ClickDrops = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "click_queue_dropped_total",
+		Help:      "Clicks dropped because the click queue was full.",
+	})
+
+	// CacheLookups counts cache lookups by cache and result, hit or miss
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Cache lookups by cache and result, hit or miss.",
+	}, []string{"cache", "result"})
+
+	// GeoIPErrors counts failed GeoIP lookups
+	GeoIPErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "geoip_lookup_errors_total",
+		Help:      "GeoIP lookups that failed.",
+	})
+
+	// RateLimitRejections counts requests refused by the rate limiter by
+	// policy route and plan
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests refused by the rate limiter, by policy route and plan.",
+	}, []string{"route", "plan"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		Redirects,
+		ClickQueueDepth,
+		ClickDrops,
+		CacheLookups,
+		GeoIPErrors,
+		RateLimitRejections,
+	)
+}
+
+// RegisterDB exports the connection pool statistics of db, labelled with
+// name
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the collected metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditLinkDisabled    = "link.disabled"
+	AuditLinkDeleted     = "link.deleted"
+	AuditKeyBanned       = "key.banned"
+	AuditReportDismissed = "report.dismissed"
+)
+
+// AuditEntry records an action taken on a link, by a person or by the
+// service itself. URLID is zero once the link has been purged.
+type AuditEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+	URLID     uint      `json:"url_id" gorm:"index;not null"`
+	ShortID   string    `json:"short_id" gorm:"not null"`
+	Action    string    `json:"action" gorm:"not null"`
+	// Actor is who took the action, e.g. "reputation_scan" for automatic ones
+	Actor  string `json:"actor" gorm:"not null"`
+	Detail string `json:"detail"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// referrerPolicies are the values accepted for the Referrer-Policy header.
+// "no-referrer" anonymizes the visit: the destination cannot tell where the
+// visitor came from.
+var referrerPolicies = map[string]bool{
+	"no-referrer":                     true,
+	"no-referrer-when-downgrade":      true,
+	"origin":                          true,
+	"origin-when-cross-origin":        true,
+	"same-origin":                     true,
+	"strict-origin":                   true,
+	"strict-origin-when-cross-origin": true,
+	"unsafe-url":                      true,
+}
+
+// RedirectProfile controls how a redirect is served. Unset fields fall back
+// to the server default profile.
+type RedirectProfile struct {
+	// StatusCode is one of 301, 302, 307 or 308
+	StatusCode int `json:"status_code,omitempty"`
+	// CacheMaxAge is how long, in seconds, browsers may cache the redirect.
+	// Zero forbids caching so every click reaches the service.
+	CacheMaxAge *int `json:"cache_max_age,omitempty"`
+	// ReferrerPolicy is sent as the Referrer-Policy header when set
+	ReferrerPolicy string `json:"referrer_policy,omitempty"`
+	// NoIndex sends X-Robots-Tag: noindex when true
+	NoIndex *bool `json:"noindex,omitempty"`
+}
+
+// Validate checks that the profile only contains supported values
+func (p RedirectProfile) Validate() error {
+	switch p.StatusCode {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("unsupported redirect status code %d", p.StatusCode)
+	}
+	if p.CacheMaxAge != nil && *p.CacheMaxAge < 0 {
+		return fmt.Errorf("cache_max_age must not be negative")
+	}
+	if p.ReferrerPolicy != "" && !referrerPolicies[p.ReferrerPolicy] {
+		return fmt.Errorf("unsupported referrer policy %q", p.ReferrerPolicy)
+	}
+	return nil
+}
+
+// WithDefaults returns the profile with unset fields taken from defaults
+func (p RedirectProfile) WithDefaults(defaults RedirectProfile) RedirectProfile {
+	if p.StatusCode == 0 {
+		p.StatusCode = defaults.StatusCode
+	}
+	if p.CacheMaxAge == nil {
+		p.CacheMaxAge = defaults.CacheMaxAge
+	}
+	if p.ReferrerPolicy == "" {
+		p.ReferrerPolicy = defaults.ReferrerPolicy
+	}
+	if p.NoIndex == nil {
+		p.NoIndex = defaults.NoIndex
+	}
+	return p
+}
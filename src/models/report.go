@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Report statuses
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusTriaged  = "triaged"
+	ReportStatusResolved = "resolved"
+)
+
+// Report is a visitor's complaint about a short URL
+type Report struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null"`
+	URLID     uint      `json:"url_id" gorm:"index;not null"`
+	ShortID   string    `json:"short_id" gorm:"not null"`
+	// Reason is the category picked by the reporter, e.g. "phishing"
+	Reason     string `json:"reason" gorm:"not null"`
+	Details    string `json:"details"`
+	ReporterIP string `json:"reporter_ip" gorm:"not null"`
+	Status     string `json:"status" gorm:"not null;default:open;index"`
+	// Resolution is the action taken when the report was resolved, e.g.
+	// "disable" or "dismiss", and Note the moderator's comment
+	Resolution string     `json:"resolution,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// BannedKey is an API key owner that may no longer create links
+type BannedKey struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+	Owner     string    `json:"owner" gorm:"uniqueIndex;not null"`
+	Reason    string    `json:"reason"`
+}
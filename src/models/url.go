@@ -6,21 +6,99 @@ import (
 	"gorm.io/gorm"
 )
 
+// URL statuses
+const (
+	URLStatusActive = "active"
+	URLStatusPaused = "paused"
+	// URLStatusHeld links were flagged as risky and await moderation
+	URLStatusHeld = "held"
+	// URLStatusDisabled links were taken down because their destination is unsafe
+	URLStatusDisabled = "disabled"
+)
+
 // URL represents a shortened URL
 type URL struct {
 	gorm.Model
 	LongURL   string     `json:"long_url" gorm:"not null"`
 	ShortID   string     `json:"short_id" gorm:"uniqueIndex;not null"`
 	ExpiresAt *time.Time `json:"expires_at"`
+	Status    string     `json:"status" gorm:"not null;default:active"`
+
+	// OriginalURL is the destination as submitted; LongURL is its canonical
+	// form, which visitors are redirected to
+	OriginalURL string `json:"original_url"`
+
+	// Usage-based expiry. MaxClicks and ExpireAfterInactive are zero when
+	// unlimited; IdleExpiresAt is pushed forward on every click.
+	MaxClicks           int        `json:"max_clicks" gorm:"not null;default:0"`
+	ClickCount          int64      `json:"click_count" gorm:"not null;default:0"`
+	ExpireAfterInactive int        `json:"expire_after_inactive" gorm:"not null;default:0"`
+	IdleExpiresAt       *time.Time `json:"idle_expires_at"`
+	LastClickedAt       *time.Time `json:"last_clicked_at"`
+
+	// Scheduled activation. Until NotBefore the link does not resolve and
+	// visitors are sent to PrelaunchURL, if set.
+	NotBefore    *time.Time `json:"not_before"`
+	PrelaunchURL string     `json:"prelaunch_url,omitempty"`
+
+	// ExpiredRedirectURL is where visitors are sent once the link has expired
+	ExpiredRedirectURL string `json:"expired_redirect_url,omitempty"`
+
+	// Redirect controls the status code and headers of the redirect
+	Redirect RedirectProfile `json:"redirect" gorm:"embedded;embeddedPrefix:redirect_"`
+
+	// PasswordHash is the bcrypt hash of the link password, if any
+	PasswordHash string `json:"-"`
+
+	// Tags are free-form labels set by the creator
+	Tags []string `json:"tags" gorm:"serializer:json"`
+
+	// Owner identifies who created the link, and URLHash is a fingerprint of
+	// the destination. Together they find links that can be reused.
+	Owner   string `json:"owner,omitempty" gorm:"not null;default:'';index:idx_urls_owner_url_hash,priority:1"`
+	URLHash string `json:"-" gorm:"not null;default:'';index:idx_urls_owner_url_hash,priority:2"`
+
+	// RiskScore (0-100) and RiskSignals record why the destination looked
+	// risky when it was last set
+	RiskScore   int      `json:"risk_score" gorm:"not null;default:0"`
+	RiskSignals []string `json:"risk_signals,omitempty" gorm:"serializer:json"`
+
+	// DisabledReason says why a disabled link was taken down, e.g. "unsafe"
+	// or the category of the report that led to it
+	DisabledReason string `json:"disabled_reason,omitempty"`
+}
+
+// PasswordProtected reports whether the URL requires a password
+func (u *URL) PasswordProtected() bool {
+	return u.PasswordHash != ""
 }
 
 // Click represents a click event on a shortened URL
 type Click struct {
-	ID        uint      `gorm:"primaryKey"`
-	URLID     uint      `gorm:"not null"`
-	IPAddress string    `gorm:"not null"`
-	UserAgent string    `gorm:"not null"`
-	Country   string    `gorm:"not null"`
-	Device    string    `gorm:"not null"`
-	CreatedAt time.Time `gorm:"not null"`
-} 
\ No newline at end of file
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	URLID     uint      `json:"url_id" gorm:"not null"`
+	IPAddress string    `json:"ip_address" gorm:"not null"`
+	UserAgent string    `json:"user_agent" gorm:"not null"`
+	Country   string    `json:"country" gorm:"not null"`
+	Device    string    `json:"device" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+
+	// Location details, when a GeoIP database is available
+	City        string  `json:"city"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Timezone    string  `json:"timezone"`
+	CountryCode string  `json:"country_code"`
+
+	// Source is the marker carried by the visited URL, e.g. "qr" for scans
+	Source string `json:"source" gorm:"index"`
+}
+
+// UnlockAttempt represents a failed attempt to unlock a password-protected URL
+type UnlockAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	URLID     uint      `json:"url_id" gorm:"index;not null"`
+	IPAddress string    `json:"ip_address" gorm:"not null"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+}
@@ -0,0 +1,236 @@
+package qr
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" as version 1-M, from the worked example in the specification
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ecc)
+}
+
+func TestVersionBits(t *testing.T) {
+	assert.Equal(t, 0x07C94, versionBits(7))
+	assert.Equal(t, 0x28C69, versionBits(40))
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		level   Level
+		version int
+	}{
+		{"short link", "http://localhost:8080/YtHDX-8?src=qr", LevelM, 3},
+		{"high redundancy", "http://localhost:8080/YtHDX-8?src=qr", LevelH, 5},
+		{"version info", strings.Repeat("a", 200), LevelQ, 12},
+		{"largest", strings.Repeat("z", 2953), LevelL, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode(tt.content, tt.level)
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, code.Version)
+			assert.Equal(t, tt.version*4+17, code.Size)
+			assert.Equal(t, tt.content, decode(t, code))
+		})
+	}
+
+	_, err := Encode(strings.Repeat("z", 2954), LevelL)
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestRender(t *testing.T) {
+	code, err := Encode("http://localhost:8080/abc", LevelM)
+	require.NoError(t, err)
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+
+	data, err := code.PNG(300, 4, red, color.White)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	// The top left corner of the finder pattern sits just inside the quiet zone
+	scale := 300 / (code.Size + 8)
+	offset := (300-scale*(code.Size+8))/2 + 4*scale
+	assert.Equal(t, color.RGBAModel.Convert(color.White), color.RGBAModel.Convert(img.At(offset-1, offset)))
+	assert.Equal(t, red, color.RGBAModel.Convert(img.At(offset, offset)))
+
+	_, err = code.PNG(10, 4, color.Black, color.White)
+	assert.Error(t, err)
+
+	svg := string(code.SVG(300, 4, red, color.White))
+	assert.Contains(t, svg, `viewBox="0 0 33 33"`)
+	assert.Contains(t, svg, `fill="#ff0000"`)
+	assert.Contains(t, svg, `fill="#ffffff"`)
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#1a2B3c")
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0x1A, G: 0x2B, B: 0x3C, A: 0xFF}, c)
+
+	for _, bad := range []string{"", "fff", "#12345g", "red"} {
+		_, err := ParseColor(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+// decode reads a code back independently of the encoder's bookkeeping:
+// it checks the format and version information, removes the mask,
+// verifies every block with the Reed-Solomon syndromes and returns the
+// byte mode payload
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+
+	// Format information, both copies
+	var first, second int
+	for i := 0; i <= 5; i++ {
+		first |= b2i(c.Black(8, i)) << i
+	}
+	first |= b2i(c.Black(8, 7))<<6 | b2i(c.Black(8, 8))<<7 | b2i(c.Black(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		first |= b2i(c.Black(14-i, 8)) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= b2i(c.Black(c.Size-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= b2i(c.Black(8, c.Size-15+i)) << i
+	}
+	require.Equal(t, first, second, "format copies differ")
+	format := first ^ 0x5412
+	require.Zero(t, polyMod(format, 0x537, 10), "format BCH")
+	assert.Equal(t, c.Level.formatBits(), format>>13)
+	mask := format >> 10 & 7
+	assert.True(t, c.Black(8, c.Size-8), "dark module")
+
+	if c.Version >= 7 {
+		var version int
+		for i := 0; i < 18; i++ {
+			version |= b2i(c.Black(c.Size-11+i%3, i/3)) << i
+		}
+		require.Zero(t, polyMod(version, 0x1F25, 12), "version BCH")
+		assert.Equal(t, c.Version, version>>12)
+	}
+
+	// Read the codewords back with the mask removed
+	unmasked := newCode(c.Version, c.Level)
+	unmasked.drawFunctionPatterns()
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !unmasked.isFunction[y][x] {
+				unmasked.modules[y][x] = c.modules[y][x]
+			}
+		}
+	}
+	unmasked.applyMask(mask)
+	var bits bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				if !unmasked.isFunction[y][right-j] {
+					bits = append(bits, unmasked.modules[y][right-j])
+				}
+			}
+		}
+	}
+	codewords := bits.bytes()
+	require.Len(t, codewords, numRawDataModules(c.Version)/8)
+
+	// Undo the interleaving and check each block
+	numBlocks := numECCBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	numShort := numBlocks - len(codewords)%numBlocks
+	shortData := len(codewords)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < shortData+1; i++ {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+	var data bitBuffer
+	for _, block := range blocks {
+		require.True(t, syndromesZero(block, eccLen), "block fails Reed-Solomon check")
+		for _, b := range block[:len(block)-eccLen] {
+			data.append(int(b), 8)
+		}
+	}
+
+	// Byte mode segment
+	read := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | b2i(data[0])
+			data = data[1:]
+		}
+		return v
+	}
+	require.Equal(t, 0x4, read(4), "mode")
+	payload := make([]byte, read(charCountBits(c.Version)))
+	for i := range payload {
+		payload[i] = byte(read(8))
+	}
+	return string(payload)
+}
+
+// syndromesZero evaluates the block polynomial at the first n powers of
+// the generator; all are zero for an undamaged block
+func syndromesZero(block []byte, n int) bool {
+	alpha := byte(1)
+	for i := 0; i < n; i++ {
+		var s byte
+		for _, b := range block {
+			s = gfMultiply(s, alpha) ^ b
+		}
+		if s != 0 {
+			return false
+		}
+		alpha = gfMultiply(alpha, 0x02)
+	}
+	return true
+}
+
+// polyMod returns the remainder of a BCH codeword divided by the generator
+func polyMod(value, generator, degree int) int {
+	for i := 30; i >= degree; i-- {
+		if value>>i&1 != 0 {
+			value ^= generator << (i - degree)
+		}
+	}
+	return value
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
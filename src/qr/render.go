@@ -0,0 +1,97 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// ParseColor parses a hex color in RRGGBB form, with or without a leading #
+func ParseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xFF}, nil
+}
+
+// hexColor formats c as #RRGGBB
+func hexColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// width returns the number of modules across the symbol including the quiet zone
+func (c *Code) width(margin int) int {
+	return c.Size + 2*margin
+}
+
+// PNG renders the code as a size x size pixel PNG with a quiet zone of
+// margin modules. Each module is drawn as a whole number of pixels; any
+// remainder is added evenly around the quiet zone.
+func (c *Code) PNG(size, margin int, fg, bg color.Color) ([]byte, error) {
+	scale := size / c.width(margin)
+	if scale < 1 {
+		return nil, fmt.Errorf("size %d is too small for a %d module code", size, c.width(margin))
+	}
+	offset := (size-scale*c.width(margin))/2 + margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{bg, fg})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := 0; px < scale; px++ {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code as a size x size SVG with a quiet zone of margin
+// modules
+func (c *Code) SVG(size, margin int, fg, bg color.Color) []byte {
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; {
+			if !c.modules[y][x] {
+				x++
+				continue
+			}
+			run := 1
+			for x+run < c.Size && c.modules[y][x+run] {
+				run++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", x+margin, y+margin, run, run)
+			x += run
+		}
+	}
+
+	var buf bytes.Buffer
+	w := c.width(margin)
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">
+<rect width="%d" height="%d" fill="%s"/>
+<path d="%s" fill="%s"/>
+</svg>
+`, size, size, w, w, w, w, hexColor(bg), path.String(), hexColor(fg))
+	return buf.Bytes()
+}
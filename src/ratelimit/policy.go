@@ -0,0 +1,271 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule limits requests to the routes matching Route. Route is "*" for
+// every route, or one of
+//
+//	/shorten           one route, by its pattern, e.g. /links/:shortID
+//	POST /shorten      one route and method
+//	/links/*           every route under a prefix
+//
+// The most specific matching rule of a plan applies.
+type Rule struct {
+	Route  string        `yaml:"route"`
+	Rate   int           `yaml:"rate"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+}
+
+// Limit returns the limit the rule enforces
+func (r Rule) Limit() Limit {
+	return Limit{Rate: r.Rate, Period: r.Period, Burst: r.Burst}
+}
+
+// Plan is a set of rate limits and a monthly link quota shared by API keys
+type Plan struct {
+	Limits []Rule `yaml:"limits"`
+	// MonthlyLinks caps the links each key may create per calendar month;
+	// zero means no quota
+	MonthlyLinks int `yaml:"monthly_links"`
+}
+
+// KeyPolicy assigns an API key to a plan and, optionally, a workspace
+type KeyPolicy struct {
+	Plan      string `yaml:"plan"`
+	Workspace string `yaml:"workspace"`
+}
+
+// Workspace is a group of API keys sharing one monthly link quota
+type Workspace struct {
+	// MonthlyLinks replaces the quota of the keys' plans; zero keeps it
+	MonthlyLinks int `yaml:"monthly_links"`
+}
+
+// Policy maps API keys and routes to rate limits and link quotas. Keys are
+// listed by their fingerprint, the owner recorded on their links. Requests
+// without a listed key are limited per IP address by the default plan, so
+// that made-up keys cannot be used to get around the limits.
+type Policy struct {
+	// Allowlist holds CIDRs, or single addresses, that are never rate limited
+	Allowlist   []string             `yaml:"allowlist"`
+	DefaultPlan string               `yaml:"default_plan"`
+	Plans       map[string]Plan      `yaml:"plans"`
+	Keys        map[string]KeyPolicy `yaml:"keys"`
+	Workspaces  map[string]Workspace `yaml:"workspaces"`
+
+	// fallback applies when no rule of the plan matches
+	fallback  *Rule
+	allowlist []netip.Prefix
+}
+
+// DefaultPolicy limits every client IP address to limit
+func DefaultPolicy(limit Limit) *Policy {
+	p := &Policy{}
+	p.SetFallback(limit)
+	return p
+}
+
+// LoadPolicy reads a policy file in YAML
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := p.init(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &p, nil
+}
+
+// NewPolicy reads the policy file at path, or returns DefaultPolicy when
+// path is empty. Routes the policy does not cover are limited by fallback.
+func NewPolicy(path string, fallback Limit) (*Policy, error) {
+	if path == "" {
+		return DefaultPolicy(fallback), nil
+	}
+	p, err := LoadPolicy(path)
+	if err != nil {
+		return nil, err
+	}
+	p.SetFallback(fallback)
+	return p, nil
+}
+
+// SetFallback sets the limit for routes that no rule of the plan covers
+func (p *Policy) SetFallback(limit Limit) {
+	p.fallback = &Rule{Route: "*", Rate: limit.Rate, Period: limit.Period, Burst: limit.Burst}
+}
+
+// init validates the policy and parses the allowlist
+func (p *Policy) init() error {
+	for _, entry := range p.Allowlist {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return fmt.Errorf("invalid allowlist entry %q", entry)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		p.allowlist = append(p.allowlist, prefix.Masked())
+	}
+
+	for name, plan := range p.Plans {
+		if plan.MonthlyLinks < 0 {
+			return fmt.Errorf("plan %s: monthly_links must not be negative", name)
+		}
+		for _, rule := range plan.Limits {
+			if rule.Route == "" {
+				return fmt.Errorf("plan %s: limit without a route", name)
+			}
+			if err := rule.Limit().Validate(); err != nil {
+				return fmt.Errorf("plan %s, route %s: %v", name, rule.Route, err)
+			}
+		}
+	}
+	if _, ok := p.Plans[p.DefaultPlan]; p.DefaultPlan != "" && !ok {
+		return fmt.Errorf("default_plan %s is not defined", p.DefaultPlan)
+	}
+	for owner, key := range p.Keys {
+		if !strings.HasPrefix(owner, "key_") {
+			return fmt.Errorf("key %s: keys must be listed by fingerprint (key_...)", owner)
+		}
+		if _, ok := p.Plans[key.Plan]; !ok {
+			return fmt.Errorf("key %s: plan %q is not defined", owner, key.Plan)
+		}
+	}
+	for name, ws := range p.Workspaces {
+		if ws.MonthlyLinks < 0 {
+			return fmt.Errorf("workspace %s: monthly_links must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// Allowlisted reports whether ip bypasses rate limits
+func (p *Policy) Allowlisted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.allowlist {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subject identifies who a request is limited as
+type Subject struct {
+	// Key is the bucket requests are counted in, by key or by IP address
+	Key string
+	// Plan is empty for requests limited by the fallback only
+	Plan      string
+	Workspace string
+}
+
+// Subject returns who a request from ip with the API key fingerprint owner
+// is limited as
+func (p *Policy) Subject(owner, ip string) Subject {
+	if key, ok := p.Keys[owner]; ok && owner != "" {
+		return Subject{Key: "key:" + owner, Plan: key.Plan, Workspace: key.Workspace}
+	}
+	return Subject{Key: "ip:" + ip, Plan: p.DefaultPlan}
+}
+
+// Rule returns the rule that limits requests of plan to the route with the
+// given method and pattern, if any
+func (p *Policy) Rule(plan, method, route string) (Rule, bool) {
+	best, bestScore := Rule{}, -1
+	for _, rule := range p.Plans[plan].Limits {
+		if score := matchRoute(rule.Route, method, route); score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+	if bestScore >= 0 {
+		return best, true
+	}
+	if p.fallback != nil && p.fallback.Rate > 0 {
+		return *p.fallback, true
+	}
+	return Rule{}, false
+}
+
+// Rules returns the rules of plan, followed by the fallback
+func (p *Policy) Rules(plan string) []Rule {
+	rules := append([]Rule(nil), p.Plans[plan].Limits...)
+	if p.fallback != nil && p.fallback.Rate > 0 {
+		rules = append(rules, *p.fallback)
+	}
+	return rules
+}
+
+// matchRoute scores how specifically pattern matches the route; -1 means no
+// match
+func matchRoute(pattern, method, route string) int {
+	if pattern == "*" {
+		return 0
+	}
+	if m, path, ok := strings.Cut(pattern, " "); ok {
+		if !strings.EqualFold(m, method) {
+			return -1
+		}
+		if score := matchRoute(strings.TrimSpace(path), method, route); score >= 0 {
+			return score + 1
+		}
+		return -1
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		if strings.HasPrefix(route, prefix) {
+			// Longer prefixes are more specific, but exact routes beat them all
+			return 1 + len(prefix)
+		}
+		return -1
+	}
+	if pattern == route {
+		return 1 << 20
+	}
+	return -1
+}
+
+// MonthlyLinks implements the services.QuotaPolicy interface. Keys in a
+// workspace share its quota; other keys each have the quota of their plan,
+// or of the default plan when they are not listed.
+func (p *Policy) MonthlyLinks(owner string) (int, []string) {
+	key, ok := p.Keys[owner]
+	if !ok {
+		return p.Plans[p.DefaultPlan].MonthlyLinks, []string{owner}
+	}
+
+	limit := p.Plans[key.Plan].MonthlyLinks
+	if key.Workspace == "" {
+		return limit, []string{owner}
+	}
+	if ws := p.Workspaces[key.Workspace]; ws.MonthlyLinks > 0 {
+		limit = ws.MonthlyLinks
+	}
+	var shared []string
+	for other, otherKey := range p.Keys {
+		if otherKey.Workspace == key.Workspace {
+			shared = append(shared, other)
+		}
+	}
+	sort.Strings(shared)
+	return limit, shared
+}
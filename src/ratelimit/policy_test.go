@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `
+allowlist:
+  - 10.0.0.0/8
+  - 2001:db8::1
+default_plan: free
+plans:
+  free:
+    monthly_links: 100
+    limits:
+      - route: POST /shorten
+        rate: 10
+        period: 1s
+        burst: 20
+      - route: /links/*
+        rate: 30
+        period: 1m
+  internal:
+    limits:
+      - route: /shorten
+        rate: 1000
+        period: 1s
+keys:
+  key_aaaa:
+    plan: internal
+  key_bbbb:
+    plan: free
+    workspace: acme
+  key_cccc:
+    plan: free
+    workspace: acme
+workspaces:
+  acme:
+    monthly_links: 5000
+`
+
+func writePolicy(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadPolicy(t *testing.T) {
+	policy, err := LoadPolicy(writePolicy(t, testPolicy))
+	require.NoError(t, err)
+	policy.SetFallback(PerMinute(60))
+
+	assert.True(t, policy.Allowlisted("10.1.2.3"))
+	assert.True(t, policy.Allowlisted("::ffff:10.1.2.3"))
+	assert.True(t, policy.Allowlisted("2001:db8::1"))
+	assert.False(t, policy.Allowlisted("192.0.2.1"))
+	assert.False(t, policy.Allowlisted("not an ip"))
+
+	// Listed keys are limited by key, everyone else by IP address
+	assert.Equal(t, Subject{Key: "key:key_aaaa", Plan: "internal"}, policy.Subject("key_aaaa", "192.0.2.1"))
+	assert.Equal(t, Subject{Key: "key:key_bbbb", Plan: "free", Workspace: "acme"}, policy.Subject("key_bbbb", "192.0.2.1"))
+	assert.Equal(t, Subject{Key: "ip:192.0.2.1", Plan: "free"}, policy.Subject("key_made_up", "192.0.2.1"))
+	assert.Equal(t, Subject{Key: "ip:192.0.2.1", Plan: "free"}, policy.Subject("", "192.0.2.1"))
+
+	tests := []struct {
+		plan, method, route string
+		want                string
+		rate                int
+	}{
+		{"free", "POST", "/shorten", "POST /shorten", 10},
+		{"free", "GET", "/links/:shortID", "/links/*", 30},
+		{"free", "GET", "/:shortID", "*", 60},
+		{"internal", "POST", "/shorten", "/shorten", 1000},
+		{"internal", "POST", "/shorten/batch", "*", 60},
+		{"", "GET", "/expand", "*", 60},
+	}
+	for _, tt := range tests {
+		rule, ok := policy.Rule(tt.plan, tt.method, tt.route)
+		require.True(t, ok, "%s %s %s", tt.plan, tt.method, tt.route)
+		assert.Equal(t, tt.want, rule.Route, "%s %s %s", tt.plan, tt.method, tt.route)
+		assert.Equal(t, tt.rate, rule.Rate, "%s %s %s", tt.plan, tt.method, tt.route)
+	}
+	rule, _ := policy.Rule("free", "POST", "/shorten")
+	assert.Equal(t, Limit{Rate: 10, Period: time.Second, Burst: 20}, rule.Limit())
+	assert.Len(t, policy.Rules("free"), 3)
+
+	// Workspaces share their quota
+	limit, shared := policy.MonthlyLinks("key_bbbb")
+	assert.Equal(t, 5000, limit)
+	assert.Equal(t, []string{"key_bbbb", "key_cccc"}, shared)
+	limit, shared = policy.MonthlyLinks("key_aaaa")
+	assert.Zero(t, limit)
+	assert.Equal(t, []string{"key_aaaa"}, shared)
+	limit, _ = policy.MonthlyLinks("key_made_up")
+	assert.Equal(t, 100, limit)
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	tests := map[string]string{
+		"bad allowlist":   "allowlist: [nope]",
+		"bad limit":       "plans: {free: {limits: [{route: '*', rate: 0, period: 1s}]}}",
+		"missing route":   "plans: {free: {limits: [{rate: 1, period: 1s}]}}",
+		"unknown default": "default_plan: gold",
+		"unknown plan":    "keys: {key_aaaa: {plan: gold}}",
+		"raw key":         "plans: {free: {}}\nkeys: {sk_live_123: {plan: free}}",
+		"bad duration":    "plans: {free: {limits: [{route: '*', rate: 1, period: soon}]}}",
+	}
+	for name, content := range tests {
+		_, err := LoadPolicy(writePolicy(t, content))
+		assert.Error(t, err, name)
+	}
+
+	// Without rules or a fallback nothing is limited
+	_, ok := DefaultPolicy(Limit{}).Rule("", "GET", "/")
+	assert.False(t, ok)
+}
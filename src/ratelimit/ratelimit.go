@@ -0,0 +1,164 @@
+// Package ratelimit limits request rates with the generic cell rate
+// algorithm (GCRA). Limits can be enforced in process or shared between
+// instances through Redis.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limit allows Rate requests per Period, with bursts of up to Burst
+// requests. A zero Burst means Rate.
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// PerMinute returns a limit of n requests a minute
+func PerMinute(n int) Limit {
+	return Limit{Rate: n, Period: time.Minute}
+}
+
+// Validate checks that the limit can be enforced
+func (l Limit) Validate() error {
+	if l.Rate < 1 || l.Period <= 0 || l.Burst < 0 {
+		return fmt.Errorf("invalid rate limit %d per %s (burst %d)", l.Rate, l.Period, l.Burst)
+	}
+	return nil
+}
+
+// Capacity returns the number of requests allowed in a burst
+func (l Limit) Capacity() int {
+	if l.Burst == 0 {
+		return l.Rate
+	}
+	return l.Burst
+}
+
+// interval is the time one request takes up
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+// tolerance is how far the theoretical arrival time may run ahead of now
+func (l Limit) tolerance() time.Duration {
+	return l.interval() * time.Duration(l.Capacity())
+}
+
+// Result is the outcome of one request against a limit
+type Result struct {
+	Allowed bool
+	// Limit is the number of requests allowed in a burst
+	Limit int
+	// Remaining is the number of requests that would be allowed right now
+	Remaining int
+	// ResetAfter is how long until the full burst is available again
+	ResetAfter time.Duration
+	// RetryAfter is how long until the next request is allowed; zero when
+	// this one was
+	RetryAfter time.Duration
+}
+
+// result builds the Result for a request, given how far the theoretical
+// arrival time runs ahead of now after it (or, for a refused request,
+// before it) and how long to wait if it was refused
+func (l Limit) result(ahead, retryAfter time.Duration) Result {
+	r := Result{
+		Allowed:    retryAfter <= 0,
+		Limit:      l.Capacity(),
+		ResetAfter: ahead,
+		RetryAfter: retryAfter,
+	}
+	if r.Allowed {
+		r.Remaining = int((l.tolerance() - ahead) / l.interval())
+	}
+	if r.ResetAfter < 0 {
+		r.ResetAfter = 0
+	}
+	return r
+}
+
+// Limiter counts a request against a limit. key identifies who is being
+// limited, e.g. a client IP address. Peek reports whether a request would be
+// allowed without counting it, for callers that only count some requests,
+// such as failed ones.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	Peek(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// sweepInterval is how often MemoryLimiter drops keys that are back to a
+// full burst
+const sweepInterval = time.Minute
+
+// MemoryLimiter enforces limits within a single process
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryLimiter creates an in-process limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		tats: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// Allow counts a request for key. It never fails.
+func (m *MemoryLimiter) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	return m.take(key, limit, true), nil
+}
+
+// Peek reports whether a request for key would be allowed. It never fails.
+func (m *MemoryLimiter) Peek(_ context.Context, key string, limit Limit) (Result, error) {
+	return m.take(key, limit, false), nil
+}
+
+// take applies a request for key, counting it if commit is set
+func (m *MemoryLimiter) take(key string, limit Limit, commit bool) Result {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	tat := m.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(limit.interval())
+	if allowAt := next.Add(-limit.tolerance()); now.Before(allowAt) {
+		return limit.result(tat.Sub(now), allowAt.Sub(now))
+	}
+	if commit {
+		m.tats[key] = next
+	}
+	return limit.result(next.Sub(now), 0)
+}
+
+// Len returns the number of keys being tracked
+func (m *MemoryLimiter) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.tats)
+}
+
+// sweep drops keys whose theoretical arrival time has passed; they carry no
+// state. The caller must hold m.mu.
+func (m *MemoryLimiter) sweep(now time.Time) {
+	for key, tat := range m.tats {
+		if !tat.After(now) {
+			delete(m.tats, key)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+	limit := Limit{Rate: 6, Period: time.Minute, Burst: 3}
+
+	// The burst is available straight away
+	for want := 2; want >= 0; want-- {
+		res, err := limiter.Allow(ctx, "a", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, want, res.Remaining)
+	}
+
+	res, _ := limiter.Allow(ctx, "a", limit)
+	assert.False(t, res.Allowed)
+	assert.Zero(t, res.Remaining)
+	assert.Equal(t, 10*time.Second, res.RetryAfter)
+	assert.Equal(t, 30*time.Second, res.ResetAfter)
+
+	res, _ = limiter.Peek(ctx, "a", limit)
+	assert.False(t, res.Allowed)
+
+	// Other keys are limited separately, and peeking does not count
+	for i := 0; i < 5; i++ {
+		res, _ = limiter.Peek(ctx, "b", limit)
+		assert.True(t, res.Allowed)
+	}
+	res, _ = limiter.Allow(ctx, "b", limit)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining)
+
+	// One request is allowed every Period/Rate after that
+	now = now.Add(10 * time.Second)
+	res, _ = limiter.Allow(ctx, "a", limit)
+	assert.True(t, res.Allowed)
+	assert.Zero(t, res.Remaining)
+	res, _ = limiter.Allow(ctx, "a", limit)
+	assert.False(t, res.Allowed)
+
+	// Keys back to a full burst are dropped
+	now = now.Add(time.Hour)
+	res, _ = limiter.Allow(ctx, "c", limit)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, limiter.Len())
+}
+
+func TestLimitValidate(t *testing.T) {
+	assert.NoError(t, PerMinute(60).Validate())
+	assert.Error(t, Limit{Rate: 0, Period: time.Minute}.Validate())
+	assert.Error(t, Limit{Rate: 1}.Validate())
+	assert.Error(t, Limit{Rate: 1, Period: time.Second, Burst: -1}.Validate())
+}
+
+func TestRedisLimiterFallback(t *testing.T) {
+	// Nothing listens on port 1, so every request falls back to memory
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	limiter := NewRedisLimiter(client, "test:")
+	limit := Limit{Rate: 2, Period: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		res, err := limiter.Allow(context.Background(), "a", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+	res, err := limiter.Allow(context.Background(), "a", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.True(t, limiter.degraded.Load())
+}
+
+// TestRedisLimiter runs against the Redis in REDIS_TEST_URL, if any
+func TestRedisLimiter(t *testing.T) {
+	url := os.Getenv("REDIS_TEST_URL")
+	if url == "" {
+		t.Skip("REDIS_TEST_URL not set")
+	}
+	opt, err := redis.ParseURL(url)
+	require.NoError(t, err)
+	client := redis.NewClient(opt)
+	defer client.Close()
+
+	key := "test:" + time.Now().Format(time.RFC3339Nano)
+	limiter := NewRedisLimiter(client, "")
+	other := NewRedisLimiter(client, "")
+	limit := Limit{Rate: 3, Period: time.Minute}
+
+	// Peeking does not count, and limiters sharing a Redis share the limit
+	res, err := limiter.Peek(context.Background(), key, limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	for want := 2; want >= 0; want-- {
+		res, err := []*RedisLimiter{limiter, other}[want%2].Allow(context.Background(), key, limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, want, res.Remaining)
+	}
+	res, err = limiter.Allow(context.Background(), key, limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.InDelta(t, 20*time.Second, res.RetryAfter, float64(time.Second))
+	assert.False(t, limiter.degraded.Load())
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/yourusername/urlshortener/src/logger"
+	"go.uber.org/zap"
+)
+
+// gcraScript applies one request to the GCRA state in KEYS[1], using the
+// Redis clock so that every instance agrees on the time. ARGV holds the
+// emission interval and the tolerance in microseconds. It returns whether
+// the request was allowed, how long to wait if not, and how far the
+// theoretical arrival time runs ahead of now, all in microseconds. An
+// allowed request is only counted when ARGV[3] is 1.
+var gcraScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - tolerance
+if now < allow_at then
+	return {0, allow_at - now, tat - now}
+end
+if ARGV[3] ~= "1" then
+	return {1, 0, new_tat - now}
+end
+redis.call("SET", KEYS[1], string.format("%.0f", new_tat), "PX", math.ceil((new_tat - now) / 1000))
+return {1, 0, new_tat - now}
+`)
+
+// RedisLimiter enforces limits shared by every instance using the same
+// Redis. While Redis cannot be reached it falls back to a MemoryLimiter, so
+// each instance keeps enforcing the limits on its own.
+type RedisLimiter struct {
+	client   *redis.Client
+	prefix   string
+	fallback *MemoryLimiter
+	timeout  time.Duration
+	degraded atomic.Bool
+}
+
+// NewRedisLimiter creates a limiter that keeps its state in Redis under
+// keys starting with prefix
+func NewRedisLimiter(client *redis.Client, prefix string) *RedisLimiter {
+	return &RedisLimiter{
+		client:   client,
+		prefix:   prefix,
+		fallback: NewMemoryLimiter(),
+		timeout:  100 * time.Millisecond,
+	}
+}
+
+// Allow counts a request for key in Redis, or in memory when Redis fails
+func (r *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return r.take(ctx, key, limit, true)
+}
+
+// Peek reports whether a request for key would be allowed, asking Redis or,
+// when Redis fails, the in-memory fallback
+func (r *RedisLimiter) Peek(ctx context.Context, key string, limit Limit) (Result, error) {
+	return r.take(ctx, key, limit, false)
+}
+
+// take applies a request for key, counting it if commit is set
+func (r *RedisLimiter) take(ctx context.Context, key string, limit Limit, commit bool) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	count := 0
+	if commit {
+		count = 1
+	}
+	reply, err := gcraScript.Run(ctx, r.client, []string{r.prefix + key},
+		limit.interval().Microseconds(), limit.tolerance().Microseconds(), count).Int64Slice()
+	if err != nil || len(reply) != 3 {
+		if !r.degraded.Swap(true) {
+			logger.Get().Warn("Rate limiter cannot reach Redis, limiting in memory",
+				zap.Error(err))
+		}
+		if commit {
+			return r.fallback.Allow(ctx, key, limit)
+		}
+		return r.fallback.Peek(ctx, key, limit)
+	}
+	if r.degraded.Swap(false) {
+		logger.Get().Info("Rate limiter reconnected to Redis")
+	}
+
+	retryAfter := time.Duration(reply[1]) * time.Microsecond
+	ahead := time.Duration(reply[2]) * time.Microsecond
+	return limit.result(ahead, retryAfter), nil
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import "sync/atomic"
+
+// PolicySource holds the policy in force, which can be replaced while
+// requests are being limited
+type PolicySource struct {
+	policy atomic.Pointer[Policy]
+}
+
+// NewPolicySource creates a source holding policy
+func NewPolicySource(policy *Policy) *PolicySource {
+	s := &PolicySource{}
+	s.Store(policy)
+	return s
+}
+
+// Load returns the policy in force
+func (s *PolicySource) Load() *Policy {
+	return s.policy.Load()
+}
+
+// Store replaces the policy in force
+func (s *PolicySource) Store(policy *Policy) {
+	s.policy.Store(policy)
+}
+
+// MonthlyLinks implements the services.QuotaPolicy interface with the
+// policy in force
+func (s *PolicySource) MonthlyLinks(owner string) (int, []string) {
+	return s.Load().MonthlyLinks(owner)
+}
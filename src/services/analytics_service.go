@@ -1,46 +1,69 @@
 package services
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/yourusername/urlshortener/src/logger"
+	"github.com/yourusername/urlshortener/src/models"
+	"github.com/yourusername/urlshortener/src/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 // AnalyticsService handles analytics-related operations
 type AnalyticsService struct {
 	db         *gorm.DB
+	logger     *zap.Logger
 	geoService *GeoService
 }
 
-// Click represents a click event
-type Click struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	URLID       uint      `json:"url_id" gorm:"index"`
-	IPAddress   string    `json:"ip_address"`
-	UserAgent   string    `json:"user_agent"`
-	DeviceType  string    `json:"device_type"`
-	Country     string    `json:"country"`
-	City        string    `json:"city"`
-	Latitude    float64   `json:"latitude"`
-	Longitude   float64   `json:"longitude"`
-	Timezone    string    `json:"timezone"`
-	CountryCode string    `json:"country_code"`
-	CreatedAt   time.Time `json:"created_at"`
-}
+// ClickSourceParam is the query parameter that marks where a visit came from
+const ClickSourceParam = "src"
+
+// ClickSourceQR marks visits from scanned QR codes
+const ClickSourceQR = "qr"
+
+// maxClickSourceLen bounds the stored source marker
+const maxClickSourceLen = 32
 
 // NewAnalyticsService creates a new analytics service instance
 func NewAnalyticsService(db *gorm.DB, geoService *GeoService) *AnalyticsService {
 	return &AnalyticsService{
 		db:         db,
+		logger:     logger.Get(),
 		geoService: geoService,
 	}
 }
 
-// RecordClick records a click event for a URL
+// WithContext returns a copy of the service whose database queries belong
+// to ctx, so that they are traced as part of the request in ctx, and whose
+// log lines carry the request's ID
+func (s *AnalyticsService) WithContext(ctx context.Context) *AnalyticsService {
+	scoped := *s
+	scoped.db = s.db.WithContext(ctx)
+	scoped.logger = logger.With(ctx, s.logger)
+	return &scoped
+}
+
+// startSpan starts a span for the method name as a child of the service's
+// context, returning a copy of the service bound to the span
+func (s *AnalyticsService) startSpan(name string) (*AnalyticsService, trace.Span) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AnalyticsService."+name)
+	scoped := *s
+	scoped.db = s.db.WithContext(ctx)
+	return &scoped, span
+}
+
+// RecordClick records a click event for a URL. It is traced as part of the
+// request r.
 func (s *AnalyticsService) RecordClick(urlID uint, r *http.Request) error {
+	s, span := s.WithContext(r.Context()).startSpan("RecordClick")
+	defer span.End()
+
 	// Get device type from user agent
 	deviceType := "unknown"
 	userAgent := r.UserAgent()
@@ -64,19 +87,25 @@ func (s *AnalyticsService) RecordClick(urlID uint, r *http.Request) error {
 	var location *Location
 	var err error
 	if s.geoService != nil {
+		_, geoSpan := tracing.Start(s.db.Statement.Context, "GeoService.GetLocation")
 		location, err = s.geoService.GetLocation(ip)
+		tracing.RecordError(geoSpan, err)
+		geoSpan.End()
 		if err != nil {
 			// Log the error but continue without location data
-			fmt.Printf("Failed to get location for IP %s: %v\n", ip, err)
+			s.logger.Warn("Failed to get location",
+				zap.Error(err),
+				zap.String("ip", ip))
 		}
 	}
 
-	click := &Click{
-		URLID:      urlID,
-		IPAddress:  ip,
-		UserAgent:  userAgent,
-		DeviceType: deviceType,
-		CreatedAt:  time.Now(),
+	click := &models.Click{
+		URLID:     urlID,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Device:    deviceType,
+		Source:    clickSource(r),
+		CreatedAt: time.Now(),
 	}
 
 	// Add location data if available
@@ -94,19 +123,22 @@ func (s *AnalyticsService) RecordClick(urlID uint, r *http.Request) error {
 
 // GetAnalytics retrieves analytics data for a URL
 func (s *AnalyticsService) GetAnalytics(urlID uint) (map[string]interface{}, error) {
+	s, span := s.startSpan("GetAnalytics")
+	defer span.End()
+
 	var totalClicks int64
-	if err := s.db.Model(&Click{}).Where("url_id = ?", urlID).Count(&totalClicks).Error; err != nil {
+	if err := s.db.Model(&models.Click{}).Where("url_id = ?", urlID).Count(&totalClicks).Error; err != nil {
 		return nil, err
 	}
 
 	var deviceStats []struct {
-		DeviceType string
-		Count      int64
+		Device string
+		Count  int64
 	}
-	if err := s.db.Model(&Click{}).
-		Select("device_type, count(*) as count").
+	if err := s.db.Model(&models.Click{}).
+		Select("device, count(*) as count").
 		Where("url_id = ?", urlID).
-		Group("device_type").
+		Group("device").
 		Scan(&deviceStats).Error; err != nil {
 		return nil, err
 	}
@@ -116,7 +148,7 @@ func (s *AnalyticsService) GetAnalytics(urlID uint) (map[string]interface{}, err
 		CountryCode string
 		Count       int64
 	}
-	if err := s.db.Model(&Click{}).
+	if err := s.db.Model(&models.Click{}).
 		Select("country, country_code, count(*) as count").
 		Where("url_id = ?", urlID).
 		Group("country, country_code").
@@ -126,7 +158,20 @@ func (s *AnalyticsService) GetAnalytics(urlID uint) (map[string]interface{}, err
 		return nil, err
 	}
 
-	var recentClicks []Click
+	var sourceStats []struct {
+		Source string
+		Count  int64
+	}
+	if err := s.db.Model(&models.Click{}).
+		Select("source, count(*) as count").
+		Where("url_id = ?", urlID).
+		Group("source").
+		Order("count DESC").
+		Scan(&sourceStats).Error; err != nil {
+		return nil, err
+	}
+
+	var recentClicks []models.Click
 	if err := s.db.Where("url_id = ?", urlID).
 		Order("created_at DESC").
 		Limit(10).
@@ -134,14 +179,37 @@ func (s *AnalyticsService) GetAnalytics(urlID uint) (map[string]interface{}, err
 		return nil, err
 	}
 
+	var failedUnlocks int64
+	if err := s.db.Model(&models.UnlockAttempt{}).Where("url_id = ?", urlID).Count(&failedUnlocks).Error; err != nil {
+		return nil, err
+	}
+
 	return map[string]interface{}{
-		"total_clicks":    totalClicks,
-		"device_stats":    deviceStats,
-		"country_stats":   countryStats,
-		"recent_clicks":   recentClicks,
+		"total_clicks":           totalClicks,
+		"failed_unlock_attempts": failedUnlocks,
+		"device_stats":           deviceStats,
+		"country_stats":          countryStats,
+		"source_stats":           sourceStats,
+		"recent_clicks":          recentClicks,
 	}, nil
 }
 
+// clickSource returns the source marker of a visit, or "" for direct
+// visits. Only short lowercase markers are kept so the column stays
+// useful for grouping.
+func clickSource(r *http.Request) string {
+	source := strings.ToLower(r.URL.Query().Get(ClickSourceParam))
+	if len(source) > maxClickSourceLen {
+		return ""
+	}
+	for _, ch := range source {
+		if (ch < 'a' || ch > 'z') && (ch < '0' || ch > '9') && ch != '-' && ch != '_' {
+			return ""
+		}
+	}
+	return source
+}
+
 // Helper functions to detect device type
 func isMobile(userAgent string) bool {
 	// Add more mobile device patterns as needed
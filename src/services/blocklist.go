@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/urlshortener/src/logger"
+	"go.uber.org/zap"
+)
+
+// Blocklist is a list of domains and patterns that links may not point to,
+// loaded from a file. Each line of the file holds one entry:
+//
+//	evil.example            a domain, blocked along with its subdomains
+//	*.login-*.example       a glob matched against the whole host
+//	/paypa[l1]-verify/      a regular expression matched against the URL
+//
+// Blank lines and text after "#" are ignored. The file can be changed while
+// the service runs; see Reload and Watch. The zero Blocklist is empty.
+type Blocklist struct {
+	mu       sync.RWMutex
+	path     string
+	modTime  time.Time
+	domains  map[string]bool
+	globs    []string
+	patterns []*regexp.Regexp
+}
+
+// LoadBlocklist reads the blocklist file at path
+func LoadBlocklist(path string) (*Blocklist, error) {
+	b := &Blocklist{path: path}
+	if _, err := b.load(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Match returns the entry that blocks rawURL, whose host is given
+// separately in lowercase ASCII form, if any
+func (b *Blocklist) Match(rawURL, host string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for domain := host; domain != ""; {
+		if b.domains[domain] {
+			return domain, true
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+	for _, glob := range b.globs {
+		if ok, _ := path.Match(glob, host); ok {
+			return glob, true
+		}
+	}
+	for _, pattern := range b.patterns {
+		if pattern.MatchString(rawURL) {
+			return "/" + pattern.String() + "/", true
+		}
+	}
+	return "", false
+}
+
+// Len returns the number of entries in the blocklist
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.domains) + len(b.globs) + len(b.patterns)
+}
+
+// Reload reads the file again if it changed since it was last loaded and
+// reports whether it did. When the new file is invalid the current entries
+// are kept.
+func (b *Blocklist) Reload() (bool, error) {
+	b.mu.RLock()
+	path, modTime := b.path, b.modTime
+	b.mu.RUnlock()
+	if path == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(modTime) {
+		return false, nil
+	}
+	return b.load()
+}
+
+// Replace swaps in the file and entries of other, or empties the blocklist
+// when other is nil
+func (b *Blocklist) Replace(other *Blocklist) {
+	if other == nil {
+		other = &Blocklist{}
+	}
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.path, b.modTime = other.path, other.modTime
+	b.domains, b.globs, b.patterns = other.domains, other.globs, other.patterns
+}
+
+// Path returns the file the blocklist is read from
+func (b *Blocklist) Path() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.path
+}
+
+// Watch reloads the blocklist every interval until ctx is cancelled
+func (b *Blocklist) Watch(ctx context.Context, interval time.Duration) {
+	log := logger.Get()
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reloaded, err := b.Reload()
+				if err != nil {
+					log.Error("Failed to reload blocklist",
+						zap.Error(err),
+						zap.String("path", b.Path()))
+					continue
+				}
+				if reloaded {
+					log.Info("Reloaded blocklist",
+						zap.String("path", b.Path()),
+						zap.Int("entries", b.Len()))
+				}
+			}
+		}
+	}()
+}
+
+// load parses the file and swaps in its entries
+func (b *Blocklist) load() (bool, error) {
+	b.mu.RLock()
+	name := b.path
+	b.mu.RUnlock()
+
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	domains := make(map[string]bool)
+	var globs []string
+	var patterns []*regexp.Regexp
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+		case len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/"):
+			pattern, err := regexp.Compile(entry[1 : len(entry)-1])
+			if err != nil {
+				return false, fmt.Errorf("%s:%d: %v", name, line, err)
+			}
+			patterns = append(patterns, pattern)
+		case strings.ContainsAny(entry, "*?["):
+			entry = strings.ToLower(entry)
+			if _, err := path.Match(entry, ""); err != nil {
+				return false, fmt.Errorf("%s:%d: %v", name, line, err)
+			}
+			globs = append(globs, entry)
+		default:
+			domain, err := canonicalHost(entry)
+			if err != nil {
+				return false, fmt.Errorf("%s:%d: invalid domain %q", name, line, entry)
+			}
+			domains[domain] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.path != name {
+		// Replaced while the file was being read
+		return false, nil
+	}
+	b.modTime = info.ModTime()
+	b.domains = domains
+	b.globs = globs
+	b.patterns = patterns
+	return true, nil
+}
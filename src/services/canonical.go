@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidURL is returned for destinations that cannot be canonicalized
+var ErrInvalidURL = errors.New("invalid URL")
+
+// DefaultTrackingParams are the query parameters removed when stripping
+// tracking parameters. A trailing "*" matches any parameter with that prefix.
+var DefaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid",
+	"mc_cid", "mc_eid", "igshid", "yclid", "_ga", "_gl",
+}
+
+// CanonicalOptions controls the optional steps of URL canonicalization
+type CanonicalOptions struct {
+	// SortQuery orders query parameters by name, keeping the order of
+	// repeated parameters
+	SortQuery bool
+	// StripTracking removes the parameters listed in TrackingParams, or
+	// DefaultTrackingParams when it is empty
+	StripTracking  bool
+	TrackingParams []string
+}
+
+// defaultPorts are dropped from canonical URLs
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// CanonicalizeURL returns the canonical form of an absolute URL: the scheme
+// and host are lowercased, internationalized hosts are converted to
+// punycode, default ports are dropped and dot segments in the path are
+// resolved. The query is sorted and tracking parameters are removed when
+// opts ask for it. The fragment is kept as is.
+func CanonicalizeURL(raw string, opts CanonicalOptions) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme == "" {
+		return "", ErrInvalidURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Opaque != "" {
+		// mailto:, tel: and the like have no host or path to normalize
+		return u.String(), nil
+	}
+	if u.Host == "" {
+		return "", ErrInvalidURL
+	}
+
+	host, err := canonicalHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	u.Host = host
+	if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	}
+	if port != "" {
+		u.Host += ":" + port
+	}
+
+	path := removeDotSegments(u.EscapedPath())
+	if path == "" {
+		path = "/"
+	}
+	if u.Path, err = url.PathUnescape(path); err != nil {
+		return "", ErrInvalidURL
+	}
+	u.RawPath = path
+
+	if opts.SortQuery || opts.StripTracking {
+		u.RawQuery = canonicalQuery(u.RawQuery, opts)
+		u.ForceQuery = false
+	}
+
+	return u.String(), nil
+}
+
+// canonicalHost lowercases a host name and converts it to its ASCII form
+func canonicalHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return "", ErrInvalidURL
+	}
+	if !isASCII(host) {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", ErrInvalidURL
+		}
+		host = ascii
+	}
+	return host, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// removeDotSegments resolves "." and ".." segments in an absolute path as
+// described in RFC 3986 section 5.2.4
+func removeDotSegments(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+
+	segments := strings.Split(path[1:], "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+			continue
+		}
+		// A trailing "." or ".." leaves the path ending in a slash
+		if last {
+			out = append(out, "")
+		}
+	}
+	return "/" + strings.Join(out, "/")
+}
+
+// canonicalQuery strips tracking parameters from a raw query and sorts it,
+// as opts ask, keeping each parameter's original encoding
+func canonicalQuery(rawQuery string, opts CanonicalOptions) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	tracking := opts.TrackingParams
+	if len(tracking) == 0 {
+		tracking = DefaultTrackingParams
+	}
+
+	type param struct {
+		name string
+		raw  string
+	}
+	var params []param
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(raw, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if opts.StripTracking && isTrackingParam(name, tracking) {
+			continue
+		}
+		params = append(params, param{name: name, raw: raw})
+	}
+
+	if opts.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			return params[i].name < params[j].name
+		})
+	}
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.raw
+	}
+	return strings.Join(parts, "&")
+}
+
+// isTrackingParam reports whether name matches one of the tracking patterns
+func isTrackingParam(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
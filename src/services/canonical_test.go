@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		opts     CanonicalOptions
+		expected string
+	}{
+		{"already canonical", "https://example.com/a?b=1#top", CanonicalOptions{}, "https://example.com/a?b=1#top"},
+		{"case of scheme and host", "HTTP://Example.COM/Path", CanonicalOptions{}, "http://example.com/Path"},
+		{"default http port", "http://example.com:80/a", CanonicalOptions{}, "http://example.com/a"},
+		{"default https port", "https://example.com:443/a", CanonicalOptions{}, "https://example.com/a"},
+		{"other port kept", "https://example.com:8443/a", CanonicalOptions{}, "https://example.com:8443/a"},
+		{"http port on https kept", "https://example.com:80/a", CanonicalOptions{}, "https://example.com:80/a"},
+		{"dot segments", "HTTP://Example.com:80/a/../b", CanonicalOptions{}, "http://example.com/b"},
+		{"single dots", "http://example.com/a/./b/.", CanonicalOptions{}, "http://example.com/a/b/"},
+		{"trailing parent", "http://example.com/a/b/..", CanonicalOptions{}, "http://example.com/a/"},
+		{"parent above root", "http://example.com/../../a", CanonicalOptions{}, "http://example.com/a"},
+		{"empty path", "http://example.com", CanonicalOptions{}, "http://example.com/"},
+		{"trailing dot in host", "http://example.com./a", CanonicalOptions{}, "http://example.com/a"},
+		{"IDN host", "https://Bücher.example/straße", CanonicalOptions{}, "https://xn--bcher-kva.example/stra%C3%9Fe"},
+		{"IPv6 literal", "http://[2001:DB8::1]:80/a", CanonicalOptions{}, "http://[2001:db8::1]/a"},
+		{"escaped path kept", "http://example.com/a%2Fb/c%20d", CanonicalOptions{}, "http://example.com/a%2Fb/c%20d"},
+		{"userinfo kept", "https://user@Example.com/", CanonicalOptions{}, "https://user@example.com/"},
+		{"query untouched by default", "http://example.com/?b=2&a=1&utm_source=x", CanonicalOptions{}, "http://example.com/?b=2&a=1&utm_source=x"},
+		{"sorted query", "http://example.com/?b=2&a=1&b=1", CanonicalOptions{SortQuery: true}, "http://example.com/?a=1&b=2&b=1"},
+		{"stripped tracking", "http://example.com/?utm_source=x&id=7&fbclid=abc&UTM_Medium=y", CanonicalOptions{StripTracking: true}, "http://example.com/?id=7"},
+		{"only tracking", "http://example.com/a?gclid=1#frag", CanonicalOptions{StripTracking: true}, "http://example.com/a#frag"},
+		{"custom tracking list", "http://example.com/?ref=x&utm_source=y", CanonicalOptions{StripTracking: true, TrackingParams: []string{"ref"}}, "http://example.com/?utm_source=y"},
+		{"sort and strip", "http://example.com/?z=1&utm_campaign=c&a=%20", CanonicalOptions{SortQuery: true, StripTracking: true}, "http://example.com/?a=%20&z=1"},
+		{"opaque URL", "MAILTO:someone@example.com", CanonicalOptions{}, "mailto:someone@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canonical, err := CanonicalizeURL(tt.raw, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, canonical)
+		})
+	}
+}
+
+func TestCanonicalizeURLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"relative", "/just/a/path"},
+		{"no host", "http:///a"},
+		{"bad escape", "http://example.com/%zz"},
+		{"invalid IDN", "http://ex­ample‍.com/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CanonicalizeURL(tt.raw, CanonicalOptions{})
+			assert.ErrorIs(t, err, ErrInvalidURL)
+		})
+	}
+}
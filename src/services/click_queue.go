@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/yourusername/urlshortener/src/logger"
+	"github.com/yourusername/urlshortener/src/metrics"
+	"github.com/yourusername/urlshortener/src/tracing"
+	"go.uber.org/zap"
+)
+
+// ErrClickQueueFull is returned when a click is dropped because too many are
+// waiting to be recorded
+var ErrClickQueueFull = errors.New("click queue is full")
+
+// ErrClickQueueBacklogged is returned by Check when the queue is nearly full
+var ErrClickQueueBacklogged = errors.New("click queue is backlogged")
+
+// DefaultClickQueueSize is how many clicks may wait to be recorded when no
+// size is configured
+const DefaultClickQueueSize = 1000
+
+// clickRecorder records one visit to a short URL
+type clickRecorder interface {
+	RecordClick(urlID uint, r *http.Request) error
+}
+
+type queuedClick struct {
+	urlID   uint
+	request *http.Request
+}
+
+// ClickQueue records clicks in the background so that redirects do not wait
+// for the database. When the queue is full new clicks are dropped rather
+// than slowing redirects down.
+type ClickQueue struct {
+	recorder clickRecorder
+	clicks   chan queuedClick
+	done     chan struct{}
+	logger   *zap.Logger
+}
+
+// NewClickQueue creates a queue holding up to size clicks for recorder. A
+// size below 1 means DefaultClickQueueSize.
+func NewClickQueue(recorder clickRecorder, size int) *ClickQueue {
+	if size < 1 {
+		size = DefaultClickQueueSize
+	}
+	return &ClickQueue{
+		recorder: recorder,
+		clicks:   make(chan queuedClick, size),
+		done:     make(chan struct{}),
+		logger:   logger.Get(),
+	}
+}
+
+// RecordClick queues a click, or returns ErrClickQueueFull if there is no
+// room for it
+func (q *ClickQueue) RecordClick(urlID uint, r *http.Request) error {
+	// The request is read after the handler returns, so it must not be
+	// cancelled along with the original. It keeps its span and request ID
+	// so the click is traced and logged with the visit.
+	ctx := logger.WithRequestID(tracing.Detach(r.Context()), logger.RequestID(r.Context()))
+	click := queuedClick{urlID: urlID, request: r.Clone(ctx)}
+	select {
+	case q.clicks <- click:
+		metrics.ClickQueueDepth.Set(float64(len(q.clicks)))
+		return nil
+	default:
+		metrics.ClickDrops.Inc()
+		return ErrClickQueueFull
+	}
+}
+
+// Start records queued clicks until ctx is cancelled, then records those
+// still waiting and closes the channel returned by Done
+func (q *ClickQueue) Start(ctx context.Context) {
+	go func() {
+		defer close(q.done)
+		for {
+			select {
+			case <-ctx.Done():
+				q.drain()
+				return
+			case click := <-q.clicks:
+				q.record(click)
+			}
+		}
+	}()
+}
+
+// Check returns ErrClickQueueBacklogged when nine tenths or more of the
+// queue is taken, meaning clicks are about to be dropped
+func (q *ClickQueue) Check(ctx context.Context) error {
+	if waiting := len(q.clicks); waiting*10 >= cap(q.clicks)*9 {
+		return fmt.Errorf("%w: %d of %d clicks waiting", ErrClickQueueBacklogged, waiting, cap(q.clicks))
+	}
+	return nil
+}
+
+// Done is closed once the queue has stopped and every queued click has
+// been recorded
+func (q *ClickQueue) Done() <-chan struct{} {
+	return q.done
+}
+
+// drain records the clicks waiting in the queue
+func (q *ClickQueue) drain() {
+	for {
+		select {
+		case click := <-q.clicks:
+			q.record(click)
+		default:
+			return
+		}
+	}
+}
+
+// record records one click, logging failures
+func (q *ClickQueue) record(click queuedClick) {
+	metrics.ClickQueueDepth.Set(float64(len(q.clicks)))
+	if err := q.recorder.RecordClick(click.urlID, click.request); err != nil {
+		logger.With(click.request.Context(), q.logger).Error("Failed to record click",
+			zap.Error(err),
+			zap.Uint("url_id", click.urlID))
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/yourusername/urlshortener/src/metrics"
+)
+
+// blockingRecorder records clicks once it is unblocked
+type blockingRecorder struct {
+	unblock chan struct{}
+	mu      sync.Mutex
+	urlIDs  []uint
+}
+
+func (r *blockingRecorder) RecordClick(urlID uint, req *http.Request) error {
+	<-r.unblock
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.urlIDs = append(r.urlIDs, urlID)
+	return nil
+}
+
+func TestClickQueue(t *testing.T) {
+	recorder := &blockingRecorder{unblock: make(chan struct{})}
+	queue := NewClickQueue(recorder, 2)
+	drops := testutil.ToFloat64(metrics.ClickDrops)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	assert.NoError(t, queue.Check(ctx))
+	for id := uint(1); id <= 2; id++ {
+		assert.NoError(t, queue.RecordClick(id, req))
+	}
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.ClickQueueDepth))
+	assert.ErrorIs(t, queue.Check(ctx), ErrClickQueueBacklogged)
+
+	// Clicks that do not fit are dropped
+	assert.ErrorIs(t, queue.RecordClick(3, req), ErrClickQueueFull)
+	assert.Equal(t, drops+1, testutil.ToFloat64(metrics.ClickDrops))
+
+	// Queued clicks are still recorded when the queue stops
+	queue.Start(ctx)
+	cancel()
+	close(recorder.unblock)
+	<-queue.Done()
+	assert.ElementsMatch(t, []uint{1, 2}, recorder.urlIDs)
+	assert.Zero(t, testutil.ToFloat64(metrics.ClickQueueDepth))
+	assert.NoError(t, queue.Check(ctx))
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDestinationRejected is matched by every PolicyViolation
+var ErrDestinationRejected = errors.New("destination rejected by policy")
+
+// Reasons a destination can be rejected for
+const (
+	ReasonSchemeNotAllowed = "scheme_not_allowed"
+	ReasonURLTooLong       = "url_too_long"
+	ReasonBlockedHost      = "blocked_host"
+	ReasonPrivateNetwork   = "private_network"
+	ReasonSelfReference    = "self_reference"
+	ReasonRedirectChain    = "redirect_chain"
+)
+
+// PolicyViolation explains why a destination was rejected
+type PolicyViolation struct {
+	Reason string // one of the Reason constants
+	Detail string
+}
+
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("%s: %s", ErrDestinationRejected, v.Detail)
+}
+
+// Is lets errors.Is match violations against ErrDestinationRejected
+func (v *PolicyViolation) Is(target error) bool {
+	return target == ErrDestinationRejected
+}
+
+// Destination policy defaults, used for fields left empty
+var (
+	DefaultAllowedSchemes = []string{"http", "https"}
+
+	// DefaultBlockedNetworks are loopback, private, link-local (including
+	// cloud metadata endpoints), shared and unspecified address ranges
+	DefaultBlockedNetworks = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("127.0.0.0/8"),
+		netip.MustParsePrefix("169.254.0.0/16"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+		netip.MustParsePrefix("192.168.0.0/16"),
+		netip.MustParsePrefix("::/128"),
+		netip.MustParsePrefix("::1/128"),
+		netip.MustParsePrefix("fc00::/7"),
+		netip.MustParsePrefix("fe80::/10"),
+	}
+)
+
+// DefaultMaxURLLength is the longest destination accepted by default
+const DefaultMaxURLLength = 2048
+
+// hostLookupTimeout bounds DNS lookups when ResolveHosts is set
+const hostLookupTimeout = 2 * time.Second
+
+// DestinationPolicy decides which destinations links may point to. Empty
+// fields fall back to the defaults above.
+type DestinationPolicy struct {
+	AllowedSchemes []string
+	// BlockedHosts are host names that may not be linked to. A leading "."
+	// also blocks every subdomain, e.g. ".example.com".
+	BlockedHosts    []string
+	BlockedNetworks []netip.Prefix
+	MaxLength       int
+	// OwnHosts are the hosts this service answers on. Links to them, or to
+	// URLs that carry them in a query parameter, would loop back here.
+	OwnHosts []string
+	// ResolveHosts also rejects host names that resolve into a blocked
+	// network. Lookups that fail are not treated as violations.
+	ResolveHosts bool
+	// LookupHost resolves host names when ResolveHosts is set; nil uses the
+	// system resolver
+	LookupHost func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// Check returns a *PolicyViolation if rawURL may not be used as a
+// destination
+func (p DestinationPolicy) Check(rawURL string) error {
+	maxLength := p.MaxLength
+	if maxLength == 0 {
+		maxLength = DefaultMaxURLLength
+	}
+	if len(rawURL) > maxLength {
+		return &PolicyViolation{ReasonURLTooLong, fmt.Sprintf("URL is longer than %d characters", maxLength)}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrInvalidURL
+	}
+
+	schemes := p.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = DefaultAllowedSchemes
+	}
+	if !containsFold(schemes, u.Scheme) {
+		return &PolicyViolation{ReasonSchemeNotAllowed, fmt.Sprintf("scheme %q is not allowed", u.Scheme)}
+	}
+	if u.Opaque != "" {
+		// Allowed opaque schemes such as mailto: have no host to check
+		return nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return ErrInvalidURL
+	}
+	if p.isOwnHost(host) {
+		return &PolicyViolation{ReasonSelfReference, "URL points back at this service"}
+	}
+	for _, blocked := range p.BlockedHosts {
+		blocked = strings.ToLower(blocked)
+		if host == strings.TrimPrefix(blocked, ".") || (strings.HasPrefix(blocked, ".") && strings.HasSuffix(host, blocked)) {
+			return &PolicyViolation{ReasonBlockedHost, fmt.Sprintf("host %s is blocked", host)}
+		}
+	}
+	if err := p.checkNetwork(host); err != nil {
+		return err
+	}
+
+	// A destination that forwards to one of our own links makes a chain
+	for _, values := range u.Query() {
+		for _, value := range values {
+			if nested, err := url.Parse(value); err == nil && nested.IsAbs() && p.isOwnHost(strings.ToLower(nested.Hostname())) {
+				return &PolicyViolation{ReasonRedirectChain, "URL forwards to a link on this service"}
+			}
+		}
+	}
+	return nil
+}
+
+// checkNetwork rejects hosts in a blocked network, either as IP literals in
+// any form browsers accept or, with ResolveHosts, by what they resolve to
+func (p DestinationPolicy) checkNetwork(host string) error {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return &PolicyViolation{ReasonPrivateNetwork, fmt.Sprintf("host %s is on a private network", host)}
+	}
+
+	if addr, ok := parseHostIP(host); ok {
+		if p.isBlockedAddr(addr) {
+			return &PolicyViolation{ReasonPrivateNetwork, fmt.Sprintf("address %s is on a private network", addr)}
+		}
+		return nil
+	}
+
+	if !p.ResolveHosts {
+		return nil
+	}
+	lookup := p.LookupHost
+	if lookup == nil {
+		lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), hostLookupTimeout)
+	defer cancel()
+	addrs, err := lookup(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if p.isBlockedAddr(addr) {
+			return &PolicyViolation{ReasonPrivateNetwork, fmt.Sprintf("host %s resolves to a private network", host)}
+		}
+	}
+	return nil
+}
+
+func (p DestinationPolicy) isBlockedAddr(addr netip.Addr) bool {
+	networks := p.BlockedNetworks
+	if len(networks) == 0 {
+		networks = DefaultBlockedNetworks
+	}
+	addr = addr.Unmap()
+	for _, network := range networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p DestinationPolicy) isOwnHost(host string) bool {
+	return host != "" && containsFold(p.OwnHosts, host)
+}
+
+// parseHostIP parses an IP literal host. Besides the usual notations it
+// accepts the shorthand IPv4 forms browsers resolve, such as 2130706433,
+// 0x7f.1 and 0177.0.0.1.
+func parseHostIP(host string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		return addr, true
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 0, 32)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		values[i] = v
+	}
+
+	// The last part fills all the remaining bytes
+	var ip uint64
+	for i, v := range values[:len(values)-1] {
+		if v > 0xFF {
+			return netip.Addr{}, false
+		}
+		ip |= v << (24 - 8*i)
+	}
+	last := values[len(values)-1]
+	if last >= 1<<(8*(5-len(values))) {
+		return netip.Addr{}, false
+	}
+	ip |= last
+	return netip.AddrFrom4([4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}), true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestinationPolicy(t *testing.T) {
+	policy := DestinationPolicy{
+		BlockedHosts: []string{"evil.example", ".tracker.example"},
+		OwnHosts:     []string{"sho.rt"},
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		reason string
+	}{
+		{"public host", "https://example.com/a", ""},
+		{"public address", "http://93.184.216.34/", ""},
+		{"javascript", "javascript:alert(1)", ReasonSchemeNotAllowed},
+		{"data", "data:text/html,hi", ReasonSchemeNotAllowed},
+		{"ftp", "ftp://example.com/file", ReasonSchemeNotAllowed},
+		{"too long", "https://example.com/" + strings.Repeat("a", DefaultMaxURLLength), ReasonURLTooLong},
+		{"blocked host", "https://evil.example/", ReasonBlockedHost},
+		{"blocked subdomain", "https://a.b.tracker.example/", ReasonBlockedHost},
+		{"parent of blocked subdomains", "https://tracker.example/", ReasonBlockedHost},
+		{"similar host allowed", "https://notevil.example/", ""},
+		{"metadata endpoint", "http://169.254.169.254/latest/meta-data/", ReasonPrivateNetwork},
+		{"private network", "http://10.1.2.3/", ReasonPrivateNetwork},
+		{"loopback", "http://127.0.0.1:8080/", ReasonPrivateNetwork},
+		{"localhost", "http://localhost/", ReasonPrivateNetwork},
+		{"localhost subdomain", "http://app.localhost/", ReasonPrivateNetwork},
+		{"IPv6 loopback", "http://[::1]/", ReasonPrivateNetwork},
+		{"IPv4-mapped IPv6", "http://[::ffff:192.168.0.1]/", ReasonPrivateNetwork},
+		{"decimal address", "http://2852039166/", ReasonPrivateNetwork},
+		{"hex address", "http://0x7f.1/", ReasonPrivateNetwork},
+		{"octal address", "http://0177.0.0.1/", ReasonPrivateNetwork},
+		{"own host", "https://sho.rt/abc", ReasonSelfReference},
+		{"own host any case", "https://SHO.RT/abc", ReasonSelfReference},
+		{"chain through query", "https://redirect.example/?to=https%3A%2F%2Fsho.rt%2Fabc", ReasonRedirectChain},
+		{"unrelated query URL", "https://redirect.example/?to=https%3A%2F%2Fexample.com%2F", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.url)
+			if tt.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var violation *PolicyViolation
+			require.True(t, errors.As(err, &violation), "expected a policy violation, got %v", err)
+			assert.Equal(t, tt.reason, violation.Reason)
+			assert.ErrorIs(t, err, ErrDestinationRejected)
+		})
+	}
+}
+
+func TestDestinationPolicyOverrides(t *testing.T) {
+	policy := DestinationPolicy{
+		AllowedSchemes:  []string{"https", "mailto"},
+		BlockedNetworks: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+		MaxLength:       40,
+	}
+
+	assert.NoError(t, policy.Check("mailto:someone@example.com"))
+	assert.ErrorIs(t, policy.Check("http://example.com/"), ErrDestinationRejected)
+	assert.ErrorIs(t, policy.Check("https://203.0.113.9/"), ErrDestinationRejected)
+	// Replacing the blocked networks replaces the defaults
+	assert.NoError(t, policy.Check("https://10.0.0.1/"))
+	assert.ErrorIs(t, policy.Check("https://example.com/"+strings.Repeat("a", 40)), ErrDestinationRejected)
+}
+
+func TestDestinationPolicyResolveHosts(t *testing.T) {
+	lookups := map[string][]netip.Addr{
+		"internal.example": {netip.MustParseAddr("192.168.1.10")},
+		"public.example":   {netip.MustParseAddr("93.184.216.34")},
+	}
+	policy := DestinationPolicy{
+		ResolveHosts: true,
+		LookupHost: func(ctx context.Context, host string) ([]netip.Addr, error) {
+			if addrs, ok := lookups[host]; ok {
+				return addrs, nil
+			}
+			return nil, errors.New("no such host")
+		},
+	}
+
+	assert.ErrorIs(t, policy.Check("https://internal.example/"), ErrDestinationRejected)
+	assert.NoError(t, policy.Check("https://public.example/"))
+	assert.NoError(t, policy.Check("https://unknown.example/"))
+
+	policy.ResolveHosts = false
+	assert.NoError(t, policy.Check("https://internal.example/"))
+}
@@ -3,9 +3,9 @@ package services
 import (
 	"fmt"
 	"net"
-	"path/filepath"
 
 	"github.com/oschwald/geoip2-golang"
+	"github.com/yourusername/urlshortener/src/metrics"
 )
 
 // GeoService handles IP-based location lookups
@@ -23,9 +23,9 @@ type Location struct {
 	CountryCode string  `json:"country_code"`
 }
 
-// NewGeoService creates a new GeoService instance
-func NewGeoService() (*GeoService, error) {
-	dbPath := filepath.Join("data", "geoip", "GeoLite2-City.mmdb")
+// NewGeoService creates a new GeoService instance reading the GeoLite2 City
+// database at dbPath
+func NewGeoService(dbPath string) (*GeoService, error) {
 	reader, err := geoip2.Open(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open GeoLite2 database: %v", err)
@@ -40,11 +40,13 @@ func NewGeoService() (*GeoService, error) {
 func (s *GeoService) GetLocation(ipStr string) (*Location, error) {
 	ip := net.ParseIP(ipStr)
 	if ip == nil {
+		metrics.GeoIPErrors.Inc()
 		return nil, fmt.Errorf("invalid IP address: %s", ipStr)
 	}
 
 	record, err := s.reader.City(ip)
 	if err != nil {
+		metrics.GeoIPErrors.Inc()
 		return nil, fmt.Errorf("failed to lookup IP: %v", err)
 	}
 
@@ -60,6 +62,12 @@ func (s *GeoService) GetLocation(ipStr string) (*Location, error) {
 	return location, nil
 }
 
+// Ping checks that the GeoLite2 database can still be read
+func (s *GeoService) Ping() error {
+	_, err := s.reader.City(net.IPv4(127, 0, 0, 1))
+	return err
+}
+
 // Close closes the GeoLite2 database reader
 func (s *GeoService) Close() error {
 	return s.reader.Close()
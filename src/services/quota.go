@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yourusername/urlshortener/src/models"
+	"gorm.io/gorm"
+)
+
+// ErrQuotaExceeded is returned when an API key has created as many links
+// this month as its quota allows
+var ErrQuotaExceeded = errors.New("monthly link quota exceeded")
+
+// QuotaPolicy sets how many links API keys may create per calendar month
+type QuotaPolicy interface {
+	// MonthlyLinks returns the monthly link quota of owner, and the owners
+	// whose links count towards the same quota, e.g. every key of a
+	// workspace. A zero limit means no quota.
+	MonthlyLinks(owner string) (limit int, shared []string)
+}
+
+// QuotaUsage is how much of its monthly link quota an owner has used
+type QuotaUsage struct {
+	// Limit is zero when the owner has no quota
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// LinkQuota returns the monthly link usage of owner. Months follow UTC, and
+// links that were deleted still count.
+func (s *URLService) LinkQuota(owner string) (QuotaUsage, error) {
+	s, span := s.startSpan("LinkQuota")
+	defer span.End()
+
+	return s.linkQuota(s.db, owner, time.Now())
+}
+
+func (s *URLService) linkQuota(db *gorm.DB, owner string, now time.Time) (QuotaUsage, error) {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	usage := QuotaUsage{ResetsAt: start.AddDate(0, 1, 0)}
+	if owner == "" || s.quota == nil {
+		return usage, nil
+	}
+
+	limit, shared := s.quota.MonthlyLinks(owner)
+	if len(shared) == 0 {
+		shared = []string{owner}
+	}
+	var used int64
+	if err := db.Unscoped().Model(&models.URL{}).
+		Where("owner IN ? AND created_at >= ?", shared, start).
+		Count(&used).Error; err != nil {
+		return usage, err
+	}
+
+	usage.Limit = limit
+	usage.Used = int(used)
+	if limit > 0 && usage.Used < limit {
+		usage.Remaining = limit - usage.Used
+	}
+	return usage, nil
+}
+
+// checkQuota returns ErrQuotaExceeded when owner may not create another link
+func (s *URLService) checkQuota(db *gorm.DB, owner string) error {
+	if owner == "" || s.quota == nil {
+		return nil
+	}
+	if limit, _ := s.quota.MonthlyLinks(owner); limit == 0 {
+		return nil
+	}
+	usage, err := s.linkQuota(db, owner, time.Now())
+	if err != nil {
+		return err
+	}
+	if usage.Limit > 0 && usage.Used >= usage.Limit {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
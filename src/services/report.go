@@ -0,0 +1,277 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yourusername/urlshortener/src/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Errors returned when reporting links and resolving reports
+var (
+	ErrInvalidReportReason = errors.New("reason must be one of phishing, malware, spam, illegal or other")
+	ErrReportTooLong       = errors.New("details must be at most 1000 characters")
+	ErrTooManyReports      = errors.New("too many reports, try again later")
+	ErrReportNotFound      = errors.New("report not found")
+	ErrReportResolved      = errors.New("report is already resolved")
+	ErrInvalidAction       = errors.New("action must be one of dismiss, disable, delete or ban")
+	ErrNoOwner             = errors.New("link was not created with an API key")
+	ErrOwnerBanned         = errors.New("API key is banned")
+)
+
+// ReportReasons are the categories a link can be reported for
+var ReportReasons = []string{"phishing", "malware", "spam", "illegal", "other"}
+
+// Actions that resolve a report
+const (
+	ReportActionDismiss = "dismiss"
+	ReportActionDisable = "disable"
+	ReportActionDelete  = "delete"
+	ReportActionBan     = "ban"
+)
+
+// maxReportDetails caps the free text of a report
+const maxReportDetails = 1000
+
+// ReportPolicy limits how many reports one IP address can file. A zero
+// MaxPerIP disables the limit.
+type ReportPolicy struct {
+	MaxPerIP int
+	Window   time.Duration
+}
+
+// ReportURL files a report about a short URL on behalf of a visitor
+func (s *URLService) ReportURL(shortID, reason, details, ip string) (*models.Report, error) {
+	s, span := s.startSpan("ReportURL")
+	defer span.End()
+
+	if !containsFold(ReportReasons, reason) {
+		return nil, ErrInvalidReportReason
+	}
+	details = strings.TrimSpace(details)
+	if utf8.RuneCountInString(details) > maxReportDetails {
+		return nil, ErrReportTooLong
+	}
+
+	var url models.URL
+	if err := s.db.Where("short_id = ?", shortID).First(&url).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrURLNotFound
+		}
+		return nil, err
+	}
+
+	key := "report|" + ip
+	if !s.attemptsLeft(key, s.report.MaxPerIP, s.report.Window) {
+		s.logger.Warn("Report limit exceeded",
+			zap.String("short_id", shortID),
+			zap.String("ip", ip))
+		return nil, ErrTooManyReports
+	}
+
+	report := &models.Report{
+		URLID:      url.ID,
+		ShortID:    url.ShortID,
+		Reason:     strings.ToLower(reason),
+		Details:    details,
+		ReporterIP: ip,
+		Status:     models.ReportStatusOpen,
+	}
+	if err := s.db.Create(report).Error; err != nil {
+		s.logger.Error("Failed to save report",
+			zap.Error(err),
+			zap.String("short_id", shortID))
+		return nil, err
+	}
+	s.recordAttempt(key, s.report.MaxPerIP, s.report.Window)
+
+	s.logger.Info("Link reported",
+		zap.Uint("report_id", report.ID),
+		zap.String("short_id", shortID),
+		zap.String("reason", report.Reason))
+	return report, nil
+}
+
+// ListReports returns the reports with the given status, oldest first. An
+// empty status lists every report that is not yet resolved.
+func (s *URLService) ListReports(status string) ([]models.Report, error) {
+	s, span := s.startSpan("ListReports")
+	defer span.End()
+
+	query := s.db.Order("id")
+	if status == "" {
+		query = query.Where("status <> ?", models.ReportStatusResolved)
+	} else {
+		query = query.Where("status = ?", status)
+	}
+
+	var reports []models.Report
+	if err := query.Find(&reports).Error; err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// TriageReport marks an open report as looked at, with an optional note
+func (s *URLService) TriageReport(id uint, note string) (*models.Report, error) {
+	s, span := s.startSpan("TriageReport")
+	defer span.End()
+
+	report, err := s.findReport(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status == models.ReportStatusResolved {
+		return nil, ErrReportResolved
+	}
+
+	changes := map[string]interface{}{"status": models.ReportStatusTriaged}
+	if note != "" {
+		changes["note"] = note
+	}
+	if err := s.db.Model(report).Updates(changes).Error; err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Triaged report",
+		zap.Uint("report_id", id),
+		zap.String("short_id", report.ShortID))
+	return report, nil
+}
+
+// ResolveReport acts on a report and closes it, along with every other
+// unresolved report about the same link:
+//
+//	dismiss  take no action
+//	disable  take the link down; visitors see a takedown notice
+//	delete   move the link to the trash
+//	ban      ban the API key that created the link and disable all its links
+//
+// Each action is recorded in the audit log of the affected links.
+func (s *URLService) ResolveReport(id uint, action, actor, note string) (*models.Report, error) {
+	s, span := s.startSpan("ResolveReport")
+	defer span.End()
+
+	var report *models.Report
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if report, err = s.findReport(tx, id); err != nil {
+			return err
+		}
+		if report.Status == models.ReportStatusResolved {
+			return ErrReportResolved
+		}
+
+		var url models.URL
+		if err := tx.Unscoped().First(&url, report.URLID).Error; err != nil {
+			return err
+		}
+		detail := fmt.Sprintf("report #%d: %s", report.ID, report.Reason)
+		if note != "" {
+			detail += " (" + note + ")"
+		}
+
+		switch action {
+		case ReportActionDismiss:
+			err = recordAudit(tx, url, models.AuditReportDismissed, actor, detail)
+		case ReportActionDisable:
+			err = s.disableURL(tx, url, report.Reason, actor, detail)
+		case ReportActionDelete:
+			if err = tx.Delete(&url).Error; err == nil {
+				err = recordAudit(tx, url, models.AuditLinkDeleted, actor, detail)
+			}
+		case ReportActionBan:
+			err = s.banOwner(tx, url, report.Reason, actor, detail)
+		default:
+			return ErrInvalidAction
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		changes := map[string]interface{}{
+			"status":      models.ReportStatusResolved,
+			"resolution":  action,
+			"resolved_at": now,
+		}
+		if note != "" {
+			changes["note"] = note
+		}
+		if err := tx.Model(&models.Report{}).
+			Where("url_id = ? AND status <> ?", report.URLID, models.ReportStatusResolved).
+			Updates(changes).Error; err != nil {
+			return err
+		}
+		report.Status = models.ReportStatusResolved
+		report.Resolution = action
+		report.ResolvedAt = &now
+		if note != "" {
+			report.Note = note
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrReportNotFound) && !errors.Is(err, ErrReportResolved) &&
+			!errors.Is(err, ErrInvalidAction) && !errors.Is(err, ErrNoOwner) {
+			s.logger.Error("Failed to resolve report",
+				zap.Error(err),
+				zap.Uint("report_id", id))
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Resolved report",
+		zap.Uint("report_id", id),
+		zap.String("short_id", report.ShortID),
+		zap.String("action", action),
+		zap.String("actor", actor))
+	return report, nil
+}
+
+// banOwner bans the key that created url and disables all of its links
+func (s *URLService) banOwner(tx *gorm.DB, url models.URL, reason, actor, detail string) error {
+	if url.Owner == "" {
+		return ErrNoOwner
+	}
+	if err := tx.Where(models.BannedKey{Owner: url.Owner}).
+		Attrs(models.BannedKey{Reason: detail}).
+		FirstOrCreate(&models.BannedKey{}).Error; err != nil {
+		return err
+	}
+	if err := recordAudit(tx, url, models.AuditKeyBanned, actor, detail); err != nil {
+		return err
+	}
+
+	var links []models.URL
+	if err := tx.Where("owner = ? AND status <> ?", url.Owner, models.URLStatusDisabled).
+		Find(&links).Error; err != nil {
+		return err
+	}
+	for _, link := range links {
+		if err := s.disableURL(tx, link, reason, actor, detail); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Warn("Banned API key",
+		zap.String("owner", url.Owner),
+		zap.Int("links_disabled", len(links)))
+	return nil
+}
+
+func (s *URLService) findReport(db *gorm.DB, id uint) (*models.Report, error) {
+	var report models.Report
+	if err := db.First(&report, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/urlshortener/src/metrics"
+)
+
+// ReasonUnsafe rejects destinations flagged by the reputation checker
+const ReasonUnsafe = "unsafe"
+
+// Verdict is a reputation checker's opinion of one URL
+type Verdict struct {
+	Malicious bool
+	// Threats names what the URL was flagged for, e.g. "MALWARE"
+	Threats []string
+	// CacheFor is how long the checker says the verdict stays valid; zero
+	// leaves it to the cache
+	CacheFor time.Duration
+}
+
+// ReputationChecker looks URLs up in an external reputation service. The
+// result has a verdict for every URL that was checked.
+type ReputationChecker interface {
+	CheckURLs(ctx context.Context, urls []string) (map[string]Verdict, error)
+}
+
+// DefaultSafeBrowsingEndpoint is the Safe Browsing v4 Lookup API
+const DefaultSafeBrowsingEndpoint = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+
+// safeBrowsingMaxURLs is the most URLs the Lookup API takes per request
+const safeBrowsingMaxURLs = 500
+
+// SafeBrowsingConfig holds the settings for a SafeBrowsingClient
+type SafeBrowsingConfig struct {
+	APIKey string
+	// Endpoint defaults to DefaultSafeBrowsingEndpoint
+	Endpoint      string
+	ClientID      string
+	ClientVersion string
+	// HTTPClient defaults to a client with a 5 second timeout
+	HTTPClient *http.Client
+}
+
+// SafeBrowsingClient checks URLs with the Safe Browsing v4 Lookup API, or
+// any service that speaks the same protocol
+type SafeBrowsingClient struct {
+	cfg SafeBrowsingConfig
+}
+
+// NewSafeBrowsingClient creates a Safe Browsing client
+func NewSafeBrowsingClient(cfg SafeBrowsingConfig) *SafeBrowsingClient {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultSafeBrowsingEndpoint
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "urlshortener"
+	}
+	if cfg.ClientVersion == "" {
+		cfg.ClientVersion = "1.0"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &SafeBrowsingClient{cfg: cfg}
+}
+
+// Safe Browsing request and response bodies
+type (
+	sbRequest struct {
+		Client     sbClient     `json:"client"`
+		ThreatInfo sbThreatInfo `json:"threatInfo"`
+	}
+	sbClient struct {
+		ClientID      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion"`
+	}
+	sbThreatInfo struct {
+		ThreatTypes      []string  `json:"threatTypes"`
+		PlatformTypes    []string  `json:"platformTypes"`
+		ThreatEntryTypes []string  `json:"threatEntryTypes"`
+		ThreatEntries    []sbEntry `json:"threatEntries"`
+	}
+	sbEntry struct {
+		URL string `json:"url"`
+	}
+	sbResponse struct {
+		Matches []struct {
+			ThreatType    string  `json:"threatType"`
+			Threat        sbEntry `json:"threat"`
+			CacheDuration string  `json:"cacheDuration"`
+		} `json:"matches"`
+	}
+)
+
+// sbThreatTypes are the threats URLs are checked for
+var sbThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+
+// CheckURLs looks the URLs up, in requests of at most 500 URLs
+func (c *SafeBrowsingClient) CheckURLs(ctx context.Context, urls []string) (map[string]Verdict, error) {
+	verdicts := make(map[string]Verdict, len(urls))
+	for start := 0; start < len(urls); start += safeBrowsingMaxURLs {
+		end := start + safeBrowsingMaxURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		if err := c.lookup(ctx, urls[start:end], verdicts); err != nil {
+			return nil, err
+		}
+	}
+	return verdicts, nil
+}
+
+func (c *SafeBrowsingClient) lookup(ctx context.Context, urls []string, verdicts map[string]Verdict) error {
+	req := sbRequest{
+		Client: sbClient{ClientID: c.cfg.ClientID, ClientVersion: c.cfg.ClientVersion},
+		ThreatInfo: sbThreatInfo{
+			ThreatTypes:      sbThreatTypes,
+			PlatformTypes:    []string{"ANY_PLATFORM"},
+			ThreatEntryTypes: []string{"URL"},
+		},
+	}
+	for _, u := range urls {
+		req.ThreatInfo.ThreatEntries = append(req.ThreatInfo.ThreatEntries, sbEntry{URL: u})
+		verdicts[u] = Verdict{}
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	endpoint := c.cfg.Endpoint + "?key=" + url.QueryEscape(c.cfg.APIKey)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		// The error includes the URL, and with it the API key
+		return fmt.Errorf("safe browsing lookup failed: %v", stripURLError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("safe browsing lookup failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result sbResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid safe browsing response: %v", err)
+	}
+	for _, match := range result.Matches {
+		verdict := verdicts[match.Threat.URL]
+		verdict.Malicious = true
+		verdict.Threats = append(verdict.Threats, match.ThreatType)
+		if d, err := time.ParseDuration(match.CacheDuration); err == nil && d > verdict.CacheFor {
+			verdict.CacheFor = d
+		}
+		verdicts[match.Threat.URL] = verdict
+	}
+	return nil
+}
+
+// stripURLError drops the request URL from HTTP client errors
+func stripURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+	return err
+}
+
+// Verdict cache defaults
+const (
+	DefaultVerdictTTL      = 30 * time.Minute
+	defaultVerdictCapacity = 10000
+)
+
+// reputationCache labels the metrics of the verdict cache
+const reputationCache = "reputation"
+
+// CachedReputationChecker remembers the verdicts of another checker so the
+// same URL is not looked up over and over
+type CachedReputationChecker struct {
+	checker  ReputationChecker
+	ttl      time.Duration
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]cachedVerdict
+}
+
+type cachedVerdict struct {
+	verdict Verdict
+	expires time.Time
+}
+
+// NewCachedReputationChecker wraps checker with a cache. Verdicts are kept
+// for ttl, or as long as the checker says if that is longer; a zero ttl
+// means DefaultVerdictTTL.
+func NewCachedReputationChecker(checker ReputationChecker, ttl time.Duration) *CachedReputationChecker {
+	if ttl == 0 {
+		ttl = DefaultVerdictTTL
+	}
+	return &CachedReputationChecker{
+		checker:  checker,
+		ttl:      ttl,
+		capacity: defaultVerdictCapacity,
+		entries:  make(map[string]cachedVerdict),
+	}
+}
+
+// CheckURLs returns cached verdicts and looks up the rest
+func (c *CachedReputationChecker) CheckURLs(ctx context.Context, urls []string) (map[string]Verdict, error) {
+	now := time.Now()
+	verdicts := make(map[string]Verdict, len(urls))
+	var missing []string
+
+	c.mu.Lock()
+	for _, u := range urls {
+		if entry, ok := c.entries[u]; ok && now.Before(entry.expires) {
+			verdicts[u] = entry.verdict
+		} else {
+			missing = append(missing, u)
+		}
+	}
+	c.mu.Unlock()
+	metrics.CacheLookups.WithLabelValues(reputationCache, metrics.CacheHit).Add(float64(len(verdicts)))
+	metrics.CacheLookups.WithLabelValues(reputationCache, metrics.CacheMiss).Add(float64(len(missing)))
+	if len(missing) == 0 {
+		return verdicts, nil
+	}
+
+	fresh, err := c.checker.CheckURLs(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries)+len(fresh) > c.capacity {
+		c.prune(now)
+	}
+	for u, verdict := range fresh {
+		verdicts[u] = verdict
+		ttl := c.ttl
+		if verdict.CacheFor > ttl {
+			ttl = verdict.CacheFor
+		}
+		c.entries[u] = cachedVerdict{verdict: verdict, expires: now.Add(ttl)}
+	}
+	return verdicts, nil
+}
+
+// prune drops expired verdicts, and everything if the cache is still full
+func (c *CachedReputationChecker) prune(now time.Time) {
+	for u, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, u)
+		}
+	}
+	if len(c.entries) >= c.capacity {
+		c.entries = make(map[string]cachedVerdict)
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSafeBrowsing stands in for the Safe Browsing Lookup API, flagging the
+// URLs in threats
+type fakeSafeBrowsing struct {
+	mu       sync.Mutex
+	threats  map[string]string
+	requests int
+	lastKey  string
+}
+
+func (f *fakeSafeBrowsing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	f.lastKey = r.URL.Query().Get("key")
+
+	var req sbRequest
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	type match struct {
+		ThreatType      string  `json:"threatType"`
+		PlatformType    string  `json:"platformType"`
+		ThreatEntryType string  `json:"threatEntryType"`
+		Threat          sbEntry `json:"threat"`
+		CacheDuration   string  `json:"cacheDuration"`
+	}
+	var matches []match
+	for _, entry := range req.ThreatInfo.ThreatEntries {
+		if threat, ok := f.threats[entry.URL]; ok {
+			matches = append(matches, match{threat, "ANY_PLATFORM", "URL", entry, "300s"})
+		}
+	}
+	// Like the real API, a clean lookup is an empty object
+	if len(matches) == 0 {
+		w.Write([]byte("{}"))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"matches": matches})
+}
+
+func newFakeSafeBrowsing(t *testing.T, threats map[string]string) (*fakeSafeBrowsing, *SafeBrowsingClient) {
+	fake := &fakeSafeBrowsing{threats: threats}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, NewSafeBrowsingClient(SafeBrowsingConfig{APIKey: "test-key", Endpoint: server.URL})
+}
+
+func TestSafeBrowsingClient(t *testing.T) {
+	fake, client := newFakeSafeBrowsing(t, map[string]string{
+		"https://malware.example/": "MALWARE",
+	})
+
+	verdicts, err := client.CheckURLs(context.Background(), []string{"https://malware.example/", "https://example.com/"})
+	require.NoError(t, err)
+	assert.Equal(t, Verdict{Malicious: true, Threats: []string{"MALWARE"}, CacheFor: 300 * time.Second}, verdicts["https://malware.example/"])
+	assert.Equal(t, Verdict{}, verdicts["https://example.com/"])
+	assert.Equal(t, "test-key", fake.lastKey)
+
+	// Large lookups are split into several requests
+	urls := make([]string, 1200)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://example.com/%d", i)
+	}
+	verdicts, err = client.CheckURLs(context.Background(), urls)
+	require.NoError(t, err)
+	assert.Len(t, verdicts, 1200)
+	assert.Equal(t, 4, fake.requests)
+}
+
+func TestSafeBrowsingClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 403, "message": "API key not valid"}}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewSafeBrowsingClient(SafeBrowsingConfig{APIKey: "secret-key", Endpoint: server.URL})
+	_, err := client.CheckURLs(context.Background(), []string{"https://example.com/"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+
+	server.Close()
+	_, err = client.CheckURLs(context.Background(), []string{"https://example.com/"})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret-key")
+}
+
+func TestCachedReputationChecker(t *testing.T) {
+	fake, client := newFakeSafeBrowsing(t, map[string]string{
+		"https://malware.example/": "MALWARE",
+	})
+	cached := NewCachedReputationChecker(client, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		verdicts, err := cached.CheckURLs(context.Background(), []string{"https://malware.example/", "https://example.com/"})
+		require.NoError(t, err)
+		assert.True(t, verdicts["https://malware.example/"].Malicious)
+		assert.False(t, verdicts["https://example.com/"].Malicious)
+	}
+	assert.Equal(t, 1, fake.requests)
+
+	// Only URLs that are not cached are looked up
+	_, err := cached.CheckURLs(context.Background(), []string{"https://example.com/", "https://other.example/"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.requests)
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Risk signals raised by RiskPolicy.Assess
+const (
+	SignalBlocklisted         = "blocklisted"
+	SignalBrandSpoof          = "brand_spoof"
+	SignalHomoglyph           = "homoglyph"
+	SignalMixedScript         = "mixed_script"
+	SignalExcessiveSubdomains = "excessive_subdomains"
+	SignalIPLiteral           = "ip_literal"
+	SignalPunycode            = "punycode"
+)
+
+// ReasonHighRisk rejects destinations scoring at or above the reject score
+const ReasonHighRisk = "high_risk"
+
+// signalScores is what each signal adds to a destination's risk score
+var signalScores = map[string]int{
+	SignalBlocklisted:         100,
+	SignalBrandSpoof:          60,
+	SignalHomoglyph:           40,
+	SignalMixedScript:         40,
+	SignalExcessiveSubdomains: 30,
+	SignalIPLiteral:           50,
+	SignalPunycode:            10,
+}
+
+// Risk policy defaults, used for fields left zero
+const (
+	DefaultHoldScore     = 50
+	DefaultRejectScore   = 80
+	DefaultMaxSubdomains = 3
+)
+
+// DefaultBrands maps commonly impersonated brands to the domains they own
+// besides those named after them. Hosts mentioning a brand outside of those
+// domains and of its name under any public suffix (google.co.uk, amazon.in,
+// facebook.net) are treated as spoofs.
+var DefaultBrands = map[string][]string{
+	"amazon":    {"amazon.com", "amazon.co.uk", "amazon.de", "amazon.fr", "amazon.co.jp", "amazon.ca", "amazonaws.com"},
+	"apple":     {"apple.com", "icloud.com"},
+	"dropbox":   {"dropbox.com"},
+	"docusign":  {"docusign.com", "docusign.net"},
+	"facebook":  {"facebook.com", "fb.com"},
+	"google":    {"google.com", "googleusercontent.com", "googleapis.com"},
+	"instagram": {"instagram.com"},
+	"linkedin":  {"linkedin.com"},
+	"microsoft": {"microsoft.com", "live.com", "office.com", "outlook.com", "microsoftonline.com"},
+	"netflix":   {"netflix.com"},
+	"paypal":    {"paypal.com", "paypal.me"},
+	"whatsapp":  {"whatsapp.com", "whatsapp.net"},
+}
+
+// confusables folds characters that look like ASCII letters onto them
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'һ': 'h', 'і': 'i', 'ї': 'i', 'ј': 'j', 'к': 'k',
+	'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's',
+	'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ӏ': 'l',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// Latin lookalikes and accented letters
+	'ɑ': 'a', 'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a',
+	'ç': 'c', 'ć': 'c', 'ɡ': 'g', 'ğ': 'g', 'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ı': 'i', 'ł': 'l', 'ḷ': 'l', 'ñ': 'n', 'ń': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o', 'ś': 's', 'ş': 's',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ý': 'y', 'ÿ': 'y', 'ź': 'z', 'ż': 'z',
+	// Digits standing in for letters
+	'0': 'o', '1': 'l', '3': 'e', '5': 's',
+}
+
+// RiskAssessment is the outcome of scoring a destination
+type RiskAssessment struct {
+	Score   int
+	Signals []string
+	// Entry is the blocklist entry that matched, if any
+	Entry string
+}
+
+// RiskPolicy scores destinations for signs of phishing. Destinations
+// scoring at least HoldScore are held for moderation and those scoring at
+// least RejectScore are refused. Zero fields fall back to the defaults.
+type RiskPolicy struct {
+	// Blocklist may be nil
+	Blocklist     *Blocklist
+	Brands        map[string][]string
+	MaxSubdomains int
+	HoldScore     int
+	RejectScore   int
+}
+
+// Assess scores a canonical destination URL
+func (p RiskPolicy) Assess(canonical string) RiskAssessment {
+	var a RiskAssessment
+	u, err := url.Parse(canonical)
+	if err != nil || u.Host == "" {
+		return a
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	signals := make(map[string]bool)
+	if p.Blocklist != nil {
+		if entry, ok := p.Blocklist.Match(canonical, host); ok {
+			signals[SignalBlocklisted] = true
+			a.Entry = entry
+		}
+	}
+
+	if _, ok := parseHostIP(host); ok {
+		signals[SignalIPLiteral] = true
+	} else {
+		p.assessHost(host, signals)
+	}
+
+	for signal := range signals {
+		a.Signals = append(a.Signals, signal)
+		a.Score += signalScores[signal]
+	}
+	sort.Strings(a.Signals)
+	if a.Score > 100 {
+		a.Score = 100
+	}
+	return a
+}
+
+// assessHost looks for spoofing signs in a host name
+func (p RiskPolicy) assessHost(host string, signals map[string]bool) {
+	maxSubdomains := p.MaxSubdomains
+	if maxSubdomains == 0 {
+		maxSubdomains = DefaultMaxSubdomains
+	}
+	labels := strings.Split(host, ".")
+	// Two labels make the registered domain; the rest are subdomains
+	if len(labels)-2 > maxSubdomains {
+		signals[SignalExcessiveSubdomains] = true
+	}
+
+	display := host
+	if strings.Contains(host, "xn--") {
+		signals[SignalPunycode] = true
+		if unicodeHost, err := idna.ToUnicode(host); err == nil {
+			display = unicodeHost
+		}
+	}
+	for _, label := range strings.Split(display, ".") {
+		if mixedScript(label) {
+			signals[SignalMixedScript] = true
+		}
+	}
+
+	brands := p.Brands
+	if brands == nil {
+		brands = DefaultBrands
+	}
+	plain := hostTokens(display, false)
+	folded := hostTokens(display, true)
+	for brand, domains := range brands {
+		if ownsHost(domains, host) || brandDomain(brand, host) {
+			continue
+		}
+		switch {
+		case plain[brand]:
+			signals[SignalBrandSpoof] = true
+		case folded[brand]:
+			signals[SignalBrandSpoof] = true
+			signals[SignalHomoglyph] = true
+		}
+	}
+}
+
+// hostTokens splits a host into its labels and their hyphen-separated
+// parts, optionally folding lookalike characters onto ASCII
+func hostTokens(host string, fold bool) map[string]bool {
+	if fold {
+		host = strings.Map(func(r rune) rune {
+			if ascii, ok := confusables[r]; ok {
+				return ascii
+			}
+			return r
+		}, host)
+		host = strings.NewReplacer("rn", "m", "vv", "w").Replace(host)
+	}
+	tokens := make(map[string]bool)
+	for _, label := range strings.Split(host, ".") {
+		tokens[label] = true
+		tokens[strings.ReplaceAll(label, "-", "")] = true
+		for _, part := range strings.Split(label, "-") {
+			tokens[part] = true
+		}
+	}
+	return tokens
+}
+
+// ownsHost reports whether host is one of domains or a subdomain of one
+func ownsHost(domains []string, host string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// brandDomain reports whether host is, or is under, a registrable domain
+// named after brand, such as google.de or www.google.co.uk for google.
+// Private suffixes like github.io do not count, since anyone can register
+// names under them.
+func brandDomain(brand, host string) bool {
+	suffix, icann := publicsuffix.PublicSuffix(host)
+	if !icann {
+		return false
+	}
+	rest, ok := strings.CutSuffix(host, "."+suffix)
+	if !ok {
+		return false
+	}
+	return rest[strings.LastIndex(rest, ".")+1:] == brand
+}
+
+// mixedScript reports whether a label mixes Latin letters with letters of
+// another script, a common sign of homoglyph spoofing
+func mixedScript(label string) bool {
+	var latin, other bool
+	for _, r := range label {
+		switch {
+		case r < unicode.MaxASCII:
+			latin = latin || unicode.IsLetter(r)
+		case unicode.Is(unicode.Latin, r):
+			latin = true
+		case unicode.IsLetter(r):
+			other = true
+		}
+	}
+	return latin && other
+}
+
+// holdScore and rejectScore apply the defaults
+func (p RiskPolicy) holdScore() int {
+	if p.HoldScore == 0 {
+		return DefaultHoldScore
+	}
+	return p.HoldScore
+}
+
+func (p RiskPolicy) rejectScore() int {
+	if p.RejectScore == 0 {
+		return DefaultRejectScore
+	}
+	return p.RejectScore
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskAssess(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		signals []string
+	}{
+		{"ordinary site", "https://example.com/a", nil},
+		{"brand's own domain", "https://www.paypal.com/signin", nil},
+		{"brand's subdomain", "https://accounts.google.com/", nil},
+		{"brand as a word", "https://pineapple.example/", nil},
+		{"brand's ccTLD", "https://www.google.co.uk/maps", nil},
+		{"brand's country domain", "https://google.de/", nil},
+		{"brand's second-level ccTLD", "https://www.amazon.in/dp/1", nil},
+		{"brand's sister domain", "https://static.facebook.net/", nil},
+		{"brand under a private suffix", "https://paypal.github.io/", []string{SignalBrandSpoof}},
+		{"brand in a ccTLD domain", "https://google-verify.co.uk/", []string{SignalBrandSpoof}},
+		{"brand domain lookalike", "https://g00gle.de/", []string{SignalBrandSpoof, SignalHomoglyph}},
+		{"brand in another domain", "https://paypal-login.example/", []string{SignalBrandSpoof}},
+		{"brand as subdomain", "https://paypal.com.verify.example/", []string{SignalBrandSpoof}},
+		{"digit lookalikes", "https://g00gle.example/", []string{SignalBrandSpoof, SignalHomoglyph}},
+		{"letter pair lookalike", "https://rnicrosoft-support.example/", []string{SignalBrandSpoof, SignalHomoglyph}},
+		{"Cyrillic lookalike", "https://xn--pypal-4ve.com/", []string{SignalBrandSpoof, SignalHomoglyph, SignalMixedScript, SignalPunycode}},
+		{"harmless IDN", "https://xn--bcher-kva.example/", []string{SignalPunycode}},
+		{"many subdomains", "https://a.b.c.d.example.com/", []string{SignalExcessiveSubdomains}},
+		{"IP literal", "http://93.184.216.34/login", []string{SignalIPLiteral}},
+	}
+
+	policy := RiskPolicy{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risk := policy.Assess(tt.url)
+			assert.Equal(t, tt.signals, risk.Signals)
+		})
+	}
+
+	assert.Equal(t, 100, policy.Assess("https://xn--pypal-4ve.com/").Score)
+	assert.Equal(t, 60, policy.Assess("https://paypal-login.example/").Score)
+	assert.Equal(t, 0, policy.Assess("https://example.com/").Score)
+}
+
+func TestBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte(`
+# Known phishing hosts
+evil.example
+*.login-*.example    # lookalike login pages
+/paypa[l1]-verify/
+`), 0o644))
+
+	list, err := LoadBlocklist(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, list.Len())
+
+	tests := []struct {
+		url, host string
+		entry     string
+	}{
+		{"https://evil.example/", "evil.example", "evil.example"},
+		{"https://www.evil.example/", "www.evil.example", "evil.example"},
+		{"https://a.login-bank.example/", "a.login-bank.example", "*.login-*.example"},
+		{"https://example.com/paypa1-verify/", "example.com", "/paypa[l1]-verify/"},
+		{"https://notevil.example/", "notevil.example", ""},
+	}
+	for _, tt := range tests {
+		entry, ok := list.Match(tt.url, tt.host)
+		assert.Equal(t, tt.entry != "", ok, tt.url)
+		assert.Equal(t, tt.entry, entry, tt.url)
+	}
+
+	// Unchanged files are not read again
+	reloaded, err := list.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// An invalid file keeps the current entries
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.WriteFile(path, []byte("/[/\n"), 0o644))
+	require.NoError(t, os.Chtimes(path, later, later))
+	_, err = list.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 3, list.Len())
+
+	later = later.Add(time.Minute)
+	require.NoError(t, os.WriteFile(path, []byte("other.example\n"), 0o644))
+	require.NoError(t, os.Chtimes(path, later, later))
+	reloaded, err = list.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	_, ok := list.Match("https://evil.example/", "evil.example")
+	assert.False(t, ok)
+	_, ok = list.Match("https://other.example/", "other.example")
+	assert.True(t, ok)
+
+	// Replacing swaps in another file; nil empties the list
+	var empty Blocklist
+	empty.Replace(list)
+	assert.Equal(t, path, empty.Path())
+	assert.Equal(t, 1, empty.Len())
+	list.Replace(nil)
+	assert.Zero(t, list.Len())
+	reloaded, err = list.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	_, err = LoadBlocklist(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/urlshortener/src/models"
+	"github.com/yourusername/urlshortener/src/ratelimit"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Errors returned when unlocking a password-protected URL
+var (
+	ErrPasswordRequired  = errors.New("URL is password protected")
+	ErrPasswordIncorrect = errors.New("incorrect password")
+	ErrTooManyAttempts   = errors.New("too many failed attempts, try again later")
+)
+
+// UnlockPolicy controls access to password-protected URLs. Secret signs the
+// unlock cookies; when empty a random secret is generated, so cookies do not
+// survive a restart. Each IP address may get the password for a URL wrong
+// MaxFailures times per FailureWindow; a zero MaxFailures disables attempt
+// limiting.
+type UnlockPolicy struct {
+	Secret        string
+	CookieTTL     time.Duration
+	MaxFailures   int
+	FailureWindow time.Duration
+}
+
+// attemptsLeft reports whether key has attempts left, allowing max attempts
+// per window. A non-positive max allows everything. The count is kept by the
+// service's rate limiter, so it is shared between instances when that
+// limiter is backed by Redis; if the limiter fails the attempt is allowed.
+func (s *URLService) attemptsLeft(key string, max int, window time.Duration) bool {
+	if max <= 0 || window <= 0 {
+		return true
+	}
+	result, err := s.attempts.Peek(s.db.Statement.Context, key,
+		ratelimit.Limit{Rate: max, Period: window})
+	if err != nil {
+		s.logger.Error("Failed to check attempt limit",
+			zap.Error(err),
+			zap.String("key", key))
+		return true
+	}
+	return result.Allowed
+}
+
+// recordAttempt counts an attempt against key, as limited by attemptsLeft
+func (s *URLService) recordAttempt(key string, max int, window time.Duration) {
+	if max <= 0 || window <= 0 {
+		return
+	}
+	if _, err := s.attempts.Allow(s.db.Statement.Context, key,
+		ratelimit.Limit{Rate: max, Period: window}); err != nil {
+		s.logger.Error("Failed to record attempt",
+			zap.Error(err),
+			zap.String("key", key))
+	}
+}
+
+// hashPassword hashes a link password for storage
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// UnlockURL checks a password for a protected URL. Failed attempts are
+// recorded against the URL and limited per IP address and URL.
+func (s *URLService) UnlockURL(shortID, password, ip, userAgent string) error {
+	s, span := s.startSpan("UnlockURL")
+	defer span.End()
+
+	now := time.Now()
+	key := "unlock|" + ip + "|" + shortID
+	if !s.attemptsLeft(key, s.unlock.MaxFailures, s.unlock.FailureWindow) {
+		s.logger.Warn("Unlock attempts exceeded",
+			zap.String("short_id", shortID),
+			zap.String("ip", ip))
+		return ErrTooManyAttempts
+	}
+
+	var url models.URL
+	if err := s.db.Where("short_id = ?", shortID).First(&url).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrURLNotFound
+		}
+		return err
+	}
+	if !url.PasswordProtected() {
+		return nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)); err != nil {
+		s.recordAttempt(key, s.unlock.MaxFailures, s.unlock.FailureWindow)
+		attempt := &models.UnlockAttempt{
+			URLID:     url.ID,
+			IPAddress: ip,
+			UserAgent: userAgent,
+			CreatedAt: now,
+		}
+		if err := s.db.Create(attempt).Error; err != nil {
+			s.logger.Error("Failed to record unlock attempt",
+				zap.Error(err),
+				zap.String("short_id", shortID))
+		}
+
+		s.logger.Warn("Incorrect password for URL",
+			zap.String("short_id", shortID),
+			zap.String("ip", ip))
+		return ErrPasswordIncorrect
+	}
+
+	s.logger.Info("Unlocked URL",
+		zap.String("short_id", shortID))
+	return nil
+}
+
+// IssueUnlockToken returns a signed token granting access to shortID, and
+// the time it expires
+func (s *URLService) IssueUnlockToken(shortID string) (string, time.Time) {
+	expires := time.Now().Add(s.unlock.CookieTTL)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + s.signUnlock(shortID, exp), expires
+}
+
+// VerifyUnlockToken reports whether token is a valid, unexpired unlock token
+// for shortID
+func (s *URLService) VerifyUnlockToken(shortID, token string) bool {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expUnix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.signUnlock(shortID, exp)))
+}
+
+// signUnlock computes the unlock token signature for shortID and expiry
+func (s *URLService) signUnlock(shortID, exp string) string {
+	mac := hmac.New(sha256.New, s.unlockSecret)
+	mac.Write([]byte(shortID + "|" + exp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newUnlockSecret returns the configured secret, or a random one if unset
+func newUnlockSecret(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -1,8 +1,12 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -12,71 +16,642 @@ import (
 
 	"github.com/yourusername/urlshortener/src/logger"
 	"github.com/yourusername/urlshortener/src/models"
+	"github.com/yourusername/urlshortener/src/ratelimit"
+	"github.com/yourusername/urlshortener/src/tracing"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// Errors returned when resolving a short ID
+var (
+	ErrURLNotFound = errors.New("URL not found")
+	ErrURLExpired  = errors.New("URL has expired")
+	ErrURLPaused   = errors.New("URL is paused")
+	ErrURLHeld     = errors.New("URL is awaiting review")
+	ErrURLDisabled = errors.New("URL has been disabled")
+
+	ErrURLNotYetActive = errors.New("URL is not active yet")
+	ErrURLGeoBlocked   = errors.New("URL is not available in your region")
+)
+
+// Errors returned when a requested expiry is not allowed
+var (
+	ErrExpiryInPast  = errors.New("expiry must be in the future")
+	ErrExpiryTooLong = errors.New("expiry exceeds the maximum allowed lifetime")
+	ErrInvalidWindow = errors.New("not_before must be earlier than the expiry")
+)
+
+// ErrInvalidRedirect is returned for redirect profiles with unsupported values
+var ErrInvalidRedirect = errors.New("invalid redirect profile")
+
+// Errors returned for custom aliases and tags
+var (
+	ErrInvalidAlias = errors.New("alias must be 3 to 64 letters, digits, '-' or '_' and not a reserved word")
+	ErrAliasTaken   = errors.New("alias is already in use")
+	ErrInvalidTags  = errors.New("at most 20 tags of up to 50 characters each are allowed")
+)
+
+// ErrBatchAborted is reported for the valid items of an all-or-nothing batch
+// that was rolled back because another item failed
+var ErrBatchAborted = errors.New("batch aborted because another item failed")
+
+// Alias and tag limits
+const (
+	minAliasLen = 3
+	maxAliasLen = 64
+	maxTags     = 20
+	maxTagLen   = 50
+)
+
+// reservedAliases are path segments used by the API itself
+var reservedAliases = map[string]bool{
+	"shorten":   true,
+	"expand":    true,
+	"links":     true,
+	"analytics": true,
+	"health":    true,
+	"livez":     true,
+	"readyz":    true,
+	"metrics":   true,
+	"quota":     true,
+	"report":    true,
+	"admin":     true,
+	"debug":     true,
+	"static":    true,
+}
+
+// ExpiryPolicy holds the server-wide expiry settings applied to new links.
+// A zero DefaultTTL means links without an explicit expiry never expire, and
+// a zero MaxTTL means there is no upper bound.
+type ExpiryPolicy struct {
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+// LinkOptions holds the optional settings for a new short URL
+type LinkOptions struct {
+	ExpiresAt           *time.Time
+	MaxClicks           int
+	ExpireAfterInactive int // days without a click before the link expires
+	Password            string
+	NotBefore           *time.Time
+	PrelaunchURL        string // where to send visitors before NotBefore
+	ExpiredRedirectURL  string // where to send visitors once the link has expired
+	Redirect            models.RedirectProfile
+	Alias               string // custom short ID; a random one is generated when empty
+	Tags                []string
+	Owner               string // who the link belongs to; see KeyOwner
+	// Reuse returns an existing active link with the same destination and
+	// owner instead of creating a new one. It is ignored for links with an
+	// alias or password, which are always created.
+	Reuse bool
+}
+
+// LinkRequest is one short URL to create in a batch
+type LinkRequest struct {
+	LongURL string
+	Options LinkOptions
+}
+
+// LinkResult is the outcome of one LinkRequest. Exactly one of URL and Err is set.
+type LinkResult struct {
+	URL *models.URL
+	Err error
+}
+
+// LinkUpdate holds the changes to apply to an existing short URL. Nil fields
+// are left unchanged.
+type LinkUpdate struct {
+	LongURL  *string
+	Redirect *models.RedirectProfile
+}
+
+// URLServiceConfig holds the policies applied by a URLService
+type URLServiceConfig struct {
+	Expiry      ExpiryPolicy
+	Unlock      UnlockPolicy
+	Canonical   CanonicalOptions
+	Destination DestinationPolicy
+	Risk        RiskPolicy
+	Report      ReportPolicy
+	// Quota limits the links API keys may create each month; nil means no
+	// quotas
+	Quota QuotaPolicy
+	// Reputation checks new destinations and rescans existing links; nil
+	// disables reputation checks
+	Reputation ReputationChecker
+	// Attempts counts unlock and report attempts; nil means an in-process
+	// limiter
+	Attempts ratelimit.Limiter
+}
+
 // URLService handles URL shortening operations
 type URLService struct {
-	db                 *gorm.DB
-	logger             *zap.Logger
-	// Rate limiting
-	rateLimiter        map[string][]time.Time
+	db           *gorm.DB
+	logger       *zap.Logger
+	expiry       ExpiryPolicy
+	unlock       UnlockPolicy
+	canonical    CanonicalOptions
+	destination  DestinationPolicy
+	risk         RiskPolicy
+	reputation   ReputationChecker
+	quota        QuotaPolicy
+	report       ReportPolicy
+	unlockSecret []byte
+	attempts     ratelimit.Limiter
 	// Geo-fencing
 	restrictedCountries map[string]bool
 }
 
 // NewURLService creates a new URL service
-func NewURLService(db *gorm.DB) *URLService {
+func NewURLService(db *gorm.DB, cfg URLServiceConfig) *URLService {
 	// Initialize restricted countries
 	restrictedCountries := map[string]bool{
 		"RU": true, // Example: Restrict Russia
 		"CN": true, // Example: Restrict China
 	}
 
+	attempts := cfg.Attempts
+	if attempts == nil {
+		attempts = ratelimit.NewMemoryLimiter()
+	}
+
 	return &URLService{
-		db:                 db,
-		logger:             logger.Get(),
-		rateLimiter:        make(map[string][]time.Time),
+		db:                  db,
+		logger:              logger.Get(),
+		expiry:              cfg.Expiry,
+		unlock:              cfg.Unlock,
+		canonical:           cfg.Canonical,
+		destination:         cfg.Destination,
+		risk:                cfg.Risk,
+		reputation:          cfg.Reputation,
+		quota:               cfg.Quota,
+		unlockSecret:        newUnlockSecret(cfg.Unlock.Secret),
+		report:              cfg.Report,
+		attempts:            attempts,
 		restrictedCountries: restrictedCountries,
 	}
 }
 
+// WithContext returns a copy of the service whose database queries belong
+// to ctx, so that they are traced as part of the request in ctx, and whose
+// log lines carry the request's ID
+func (s *URLService) WithContext(ctx context.Context) *URLService {
+	scoped := *s
+	scoped.db = s.db.WithContext(ctx)
+	scoped.logger = logger.With(ctx, s.logger)
+	return &scoped
+}
+
+// startSpan starts a span for the method name as a child of the service's
+// context, returning a copy of the service bound to the span
+func (s *URLService) startSpan(name string) (*URLService, trace.Span) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "URLService."+name)
+	scoped := *s
+	scoped.db = s.db.WithContext(ctx)
+	return &scoped, span
+}
+
 // CreateShortURL creates a new shortened URL
-func (s *URLService) CreateShortURL(longURL string, expiresAt *time.Time) (*models.URL, error) {
-	// Generate a random short ID
-	shortID, err := generateShortID()
+func (s *URLService) CreateShortURL(longURL string, opts LinkOptions) (*models.URL, error) {
+	s, span := s.startSpan("CreateShortURL")
+	defer span.End()
+
+	url, err := s.newURL(longURL, opts, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.insertURL(s.db, url, opts.reusable())
+	if err != nil {
+		return nil, err
+	}
+	if url.Status == models.URLStatusHeld {
+		s.logger.Warn("Holding short URL for moderation",
+			zap.String("short_id", url.ShortID),
+			zap.String("long_url", longURL),
+			zap.Int("risk_score", url.RiskScore),
+			zap.Strings("risk_signals", url.RiskSignals))
+	}
+	if stored != url {
+		s.logger.Info("Reused existing short URL",
+			zap.String("short_id", stored.ShortID),
+			zap.String("long_url", longURL),
+			zap.String("owner", opts.Owner))
+		return stored, nil
+	}
+
+	s.logger.Info("Created new short URL",
+		zap.String("short_id", url.ShortID),
+		zap.String("long_url", longURL),
+		zap.Timep("expires_at", url.ExpiresAt),
+		zap.Int("max_clicks", opts.MaxClicks),
+		zap.Int("expire_after_inactive", opts.ExpireAfterInactive))
+
+	return url, nil
+}
+
+// CreateShortURLs creates a batch of short URLs and returns one result per
+// request, in order. When atomic is true the URLs are inserted in a single
+// transaction and nothing is created unless every request succeeds; the
+// requests that were fine in a failed batch report ErrBatchAborted.
+// Otherwise each URL is created independently. The error is only set when
+// the batch as a whole could not be processed.
+func (s *URLService) CreateShortURLs(reqs []LinkRequest, atomic bool) ([]LinkResult, error) {
+	s, span := s.startSpan("CreateShortURLs")
+	defer span.End()
+
+	now := time.Now()
+	results := make([]LinkResult, len(reqs))
+	failed := false
+	for i, req := range reqs {
+		results[i].URL, results[i].Err = s.newURL(req.LongURL, req.Options, now)
+		failed = failed || results[i].Err != nil
+	}
+
+	if !atomic {
+		for i := range results {
+			if results[i].Err != nil {
+				continue
+			}
+			stored, err := s.insertURL(s.db, results[i].URL, reqs[i].Options.reusable())
+			results[i] = LinkResult{URL: stored, Err: err}
+		}
+		s.logBatch(results, atomic)
+		return results, nil
+	}
+
+	if !failed {
+		errRollback := errors.New("rollback")
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			for i := range results {
+				stored, err := s.insertURL(tx, results[i].URL, reqs[i].Options.reusable())
+				results[i] = LinkResult{URL: stored, Err: err}
+				if err != nil {
+					return errRollback
+				}
+			}
+			return nil
+		})
+		switch {
+		case errors.Is(err, errRollback):
+			failed = true
+		case err != nil:
+			s.logger.Error("Failed to commit URL batch",
+				zap.Error(err),
+				zap.Int("count", len(reqs)))
+			return nil, err
+		}
+	}
+	if failed {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = LinkResult{Err: ErrBatchAborted}
+			}
+		}
+	}
+	s.logBatch(results, atomic)
+	return results, nil
+}
+
+// logBatch logs the outcome of a batch
+func (s *URLService) logBatch(results []LinkResult, atomic bool) {
+	created := 0
+	for _, result := range results {
+		if result.Err == nil {
+			created++
+		}
+	}
+	s.logger.Info("Processed URL batch",
+		zap.Int("items", len(results)),
+		zap.Int("created", created),
+		zap.Bool("atomic", atomic))
+}
+
+// newURL validates the options and builds the record for a new short URL
+// without saving it
+func (s *URLService) newURL(longURL string, opts LinkOptions, now time.Time) (*models.URL, error) {
+	canonical, risk, err := s.checkDestination(longURL)
 	if err != nil {
+		return nil, err
+	}
+	// Visitors may be sent to the fallbacks instead, so the link is as risky
+	// as the riskiest of them
+	for _, fallback := range []string{opts.PrelaunchURL, opts.ExpiredRedirectURL} {
+		fallbackRisk, err := s.checkFallback(fallback)
+		if err != nil {
+			return nil, err
+		}
+		if fallbackRisk.Score > risk.Score {
+			risk = fallbackRisk
+		}
+	}
+	expiresAt, err := s.resolveExpiry(opts.ExpiresAt, now)
+	if err != nil {
+		return nil, err
+	}
+	if opts.NotBefore != nil && expiresAt != nil && !opts.NotBefore.Before(*expiresAt) {
+		return nil, ErrInvalidWindow
+	}
+	if err := opts.Redirect.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRedirect, err)
+	}
+	tags, err := normalizeTags(opts.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	shortID := opts.Alias
+	if shortID != "" {
+		if !validAlias(shortID) {
+			return nil, ErrInvalidAlias
+		}
+	} else if shortID, err = generateShortID(); err != nil {
 		s.logger.Error("Failed to generate short ID",
 			zap.Error(err))
 		return nil, err
 	}
 
-	// Create URL record
 	url := &models.URL{
-		ShortID:   shortID,
-		LongURL:   longURL,
-		ExpiresAt: expiresAt,
+		ShortID:             shortID,
+		LongURL:             canonical,
+		OriginalURL:         longURL,
+		ExpiresAt:           expiresAt,
+		MaxClicks:           opts.MaxClicks,
+		ExpireAfterInactive: opts.ExpireAfterInactive,
+		NotBefore:           opts.NotBefore,
+		PrelaunchURL:        opts.PrelaunchURL,
+		ExpiredRedirectURL:  opts.ExpiredRedirectURL,
+		Redirect:            opts.Redirect,
+		Tags:                tags,
+		Owner:               opts.Owner,
+		URLHash:             hashURL(canonical),
+		RiskScore:           risk.Score,
+		RiskSignals:         risk.Signals,
+	}
+	if risk.Score >= s.risk.holdScore() {
+		url.Status = models.URLStatusHeld
+	}
+	if opts.ExpireAfterInactive > 0 {
+		// The inactivity clock starts at go-live for scheduled links
+		idleFrom := now
+		if opts.NotBefore != nil && opts.NotBefore.After(now) {
+			idleFrom = *opts.NotBefore
+		}
+		idleExpiresAt := idleFrom.AddDate(0, 0, opts.ExpireAfterInactive)
+		url.IdleExpiresAt = &idleExpiresAt
+	}
+	if opts.Password != "" {
+		if url.PasswordHash, err = hashPassword(opts.Password); err != nil {
+			s.logger.Error("Failed to hash URL password",
+				zap.Error(err))
+			return nil, err
+		}
+	}
+	return url, nil
+}
+
+// insertURL saves a new URL record and returns it. When reuse is set and
+// the owner already has an active link to the same destination, that link
+// is returned instead and nothing is saved.
+//
+// Short IDs stay reserved while a link is in the trash, so a clash with a
+// deleted link is reported as ErrAliasTaken too. Owners whose key was banned
+// get ErrOwnerBanned.
+func (s *URLService) insertURL(db *gorm.DB, url *models.URL, reuse bool) (*models.URL, error) {
+	if url.Owner != "" {
+		var banned int64
+		if err := db.Model(&models.BannedKey{}).Where("owner = ?", url.Owner).Count(&banned).Error; err != nil {
+			return nil, err
+		}
+		if banned > 0 {
+			return nil, ErrOwnerBanned
+		}
+	}
+	if reuse {
+		existing, err := findReusableURL(db, url, time.Now())
+		if err != nil {
+			s.logger.Error("Failed to look up reusable URL",
+				zap.Error(err),
+				zap.String("long_url", url.LongURL))
+			return nil, err
+		}
+		if existing != nil {
+			return existing, nil
+		}
+	}
+
+	var existing int64
+	if err := db.Unscoped().Model(&models.URL{}).Where("short_id = ?", url.ShortID).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrAliasTaken
+	}
+	if err := s.checkQuota(db, url.Owner); err != nil {
+		return nil, err
 	}
 
-	if err := s.db.Create(url).Error; err != nil {
+	if err := db.Create(url).Error; err != nil {
 		s.logger.Error("Failed to create URL record",
 			zap.Error(err),
-			zap.String("short_id", shortID),
-			zap.String("long_url", longURL))
+			zap.String("short_id", url.ShortID),
+			zap.String("long_url", url.LongURL))
 		return nil, err
 	}
+	return url, nil
+}
 
-	s.logger.Info("Created new short URL",
-		zap.String("short_id", shortID),
-		zap.String("long_url", longURL),
-		zap.Timep("expires_at", expiresAt))
+// findReusableURL returns the oldest link of the same owner and destination
+// that visitors can follow without a password, or nil if there is none
+func findReusableURL(db *gorm.DB, url *models.URL, now time.Time) (*models.URL, error) {
+	var existing models.URL
+	err := db.Where("owner = ? AND url_hash = ?", url.Owner, url.URLHash).
+		Where("status = ? AND password_hash = ''", models.URLStatusActive).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Where("idle_expires_at IS NULL OR idle_expires_at > ?", now).
+		Where("not_before IS NULL OR not_before <= ?", now).
+		Where("max_clicks = 0 OR click_count < max_clicks").
+		Order("id").
+		First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
 
-	return url, nil
+// reusable reports whether an existing link may be returned for these options
+func (o LinkOptions) reusable() bool {
+	return o.Reuse && o.Alias == "" && o.Password == ""
+}
+
+// checkDestination canonicalizes a destination, checks it against the
+// destination policy and scores its risk. Rejections are logged.
+func (s *URLService) checkDestination(longURL string) (string, RiskAssessment, error) {
+	canonical, err := CanonicalizeURL(longURL, s.canonical)
+	if err != nil {
+		return "", RiskAssessment{}, err
+	}
+	if err := s.destination.Check(canonical); err != nil {
+		var violation *PolicyViolation
+		if errors.As(err, &violation) {
+			s.logger.Warn("Rejected destination",
+				zap.String("reason", violation.Reason),
+				zap.String("long_url", canonical))
+		}
+		return "", RiskAssessment{}, err
+	}
+
+	risk := s.risk.Assess(canonical)
+	if risk.Score >= s.risk.rejectScore() {
+		s.logger.Warn("Rejected risky destination",
+			zap.String("long_url", canonical),
+			zap.Int("risk_score", risk.Score),
+			zap.Strings("risk_signals", risk.Signals),
+			zap.String("blocklist_entry", risk.Entry))
+		violation := &PolicyViolation{ReasonHighRisk, "URL looks like phishing (" + strings.Join(risk.Signals, ", ") + ")"}
+		if risk.Entry != "" {
+			violation.Reason = ReasonBlockedHost
+			violation.Detail = "URL matches the blocklist"
+		}
+		return "", risk, violation
+	}
+
+	if verdict, ok := s.checkReputation(canonical); ok && verdict.Malicious {
+		s.logger.Warn("Rejected unsafe destination",
+			zap.String("long_url", canonical),
+			zap.Strings("threats", verdict.Threats))
+		return "", risk, &PolicyViolation{ReasonUnsafe, "URL is flagged as unsafe (" + strings.Join(verdict.Threats, ", ") + ")"}
+	}
+	return canonical, risk, nil
+}
+
+// checkFallback checks a prelaunch or expired fallback URL. Paths stay on
+// this service; anything else must pass the same checks as a destination.
+func (s *URLService) checkFallback(fallback string) (RiskAssessment, error) {
+	if fallback == "" {
+		return RiskAssessment{}, nil
+	}
+	u, err := url.Parse(fallback)
+	if err != nil {
+		return RiskAssessment{}, ErrInvalidURL
+	}
+	if !u.IsAbs() {
+		// "//host/x" and "/\host/x" leave the service in a browser
+		if u.Host != "" || !strings.HasPrefix(fallback, "/") ||
+			strings.HasPrefix(fallback, "//") || strings.HasPrefix(fallback, "/\\") {
+			return RiskAssessment{}, fmt.Errorf("%w: fallback must be an absolute URL or a path", ErrInvalidURL)
+		}
+		return RiskAssessment{}, nil
+	}
+	_, risk, err := s.checkDestination(fallback)
+	return risk, err
+}
+
+// checkReputation looks a destination up with the reputation checker. It
+// reports false when there is no checker or the lookup failed, in which
+// case the destination is let through.
+func (s *URLService) checkReputation(canonical string) (Verdict, bool) {
+	if s.reputation == nil {
+		return Verdict{}, false
+	}
+	// The lookup is traced with the request but not cancelled with it
+	ctx, cancel := context.WithTimeout(tracing.Detach(s.db.Statement.Context), reputationTimeout)
+	defer cancel()
+	verdicts, err := s.reputation.CheckURLs(ctx, []string{canonical})
+	if err != nil {
+		s.logger.Error("Reputation check failed",
+			zap.Error(err),
+			zap.String("long_url", canonical))
+		return Verdict{}, false
+	}
+	verdict, ok := verdicts[canonical]
+	return verdict, ok
+}
+
+// hashURL returns the lookup key for a canonical destination
+func hashURL(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyOwner returns the owner recorded on links created with an API key. The
+// key itself is never stored; owners are "key_" plus a fingerprint of it.
+// Requests without a key have no owner.
+func KeyOwner(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key_" + hex.EncodeToString(sum[:8])
+}
+
+// validAlias reports whether alias can be used as a custom short ID
+func validAlias(alias string) bool {
+	if len(alias) < minAliasLen || len(alias) > maxAliasLen || reservedAliases[strings.ToLower(alias)] {
+		return false
+	}
+	for _, ch := range alias {
+		if (ch < 'a' || ch > 'z') && (ch < 'A' || ch > 'Z') && (ch < '0' || ch > '9') && ch != '-' && ch != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones
+func normalizeTags(tags []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxTagLen {
+			return nil, ErrInvalidTags
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	if len(result) > maxTags {
+		return nil, ErrInvalidTags
+	}
+	return result, nil
+}
+
+// resolveExpiry applies the server-wide default and maximum to a requested expiry
+func (s *URLService) resolveExpiry(expiresAt *time.Time, now time.Time) (*time.Time, error) {
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, ErrExpiryInPast
 	}
 
+	if expiresAt == nil {
+		ttl := s.expiry.DefaultTTL
+		if ttl == 0 {
+			ttl = s.expiry.MaxTTL
+		}
+		if ttl == 0 {
+			return nil, nil
+		}
+		t := now.Add(ttl)
+		return &t, nil
+	}
+
+	if s.expiry.MaxTTL > 0 && expiresAt.After(now.Add(s.expiry.MaxTTL)) {
+		return nil, ErrExpiryTooLong
+	}
+	return expiresAt, nil
+}
+
 // GetURLByShortID retrieves a URL by its short ID
 func (s *URLService) GetURLByShortID(shortID string) (*models.URL, error) {
+	s, span := s.startSpan("GetURLByShortID")
+	defer span.End()
+
 	var url models.URL
 	if err := s.db.Where("short_id = ? AND (expires_at IS NULL OR expires_at > ?)", shortID, time.Now()).First(&url).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -87,34 +662,559 @@ func (s *URLService) GetURLByShortID(shortID string) (*models.URL, error) {
 	return &url, nil
 }
 
-// GetLongURL retrieves the original URL for a given short ID
-func (s *URLService) GetLongURL(shortID string) (string, error) {
+// ResolveURL retrieves the URL record for a given short ID and counts the
+// visit against the link's click and inactivity limits. Password-protected
+// URLs are only resolved when unlocked is true.
+//
+// When the URL exists but cannot be followed right now (it is expired,
+// paused, scheduled or locked) the record is returned along with the error so
+// callers can serve the appropriate fallback.
+func (s *URLService) ResolveURL(shortID string, unlocked bool) (*models.URL, error) {
+	s, span := s.startSpan("ResolveURL")
+	defer span.End()
+
+	url, err := s.InspectURL(shortID, unlocked)
+	if err != nil {
+		return url, err
+	}
+
+	now := time.Now()
+	// Count the visit. The limits are re-checked in the UPDATE itself so that
+	// concurrent redirects cannot push a link past max_clicks or revive it
+	// after it has gone idle.
+	updates := map[string]interface{}{
+		"click_count":     gorm.Expr("click_count + 1"),
+		"last_clicked_at": now,
+	}
+	if url.ExpireAfterInactive > 0 {
+		updates["idle_expires_at"] = now.AddDate(0, 0, url.ExpireAfterInactive)
+	}
+	result := s.db.Model(&models.URL{}).
+		Where("id = ?", url.ID).
+		Where("max_clicks = 0 OR click_count < max_clicks").
+		Where("idle_expires_at IS NULL OR idle_expires_at > ?", now).
+		Updates(updates)
+	if result.Error != nil {
+		s.logger.Error("Failed to record visit",
+			zap.Error(result.Error),
+			zap.String("short_id", shortID))
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		s.logger.Warn("URL reached its click or inactivity limit",
+			zap.String("short_id", shortID))
+		return url, ErrURLExpired
+	}
+
+	s.logger.Info("Retrieved long URL",
+		zap.String("short_id", shortID),
+		zap.String("long_url", url.LongURL))
+
+	return url, nil
+}
+
+// InspectURL retrieves the URL record for a given short ID, applying the same
+// rules as ResolveURL without counting a visit. It is used to show where a
+// link goes without following it.
+func (s *URLService) InspectURL(shortID string, unlocked bool) (*models.URL, error) {
+	s, span := s.startSpan("InspectURL")
+	defer span.End()
+
 	var url models.URL
 	if err := s.db.Where("short_id = ?", shortID).First(&url).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logger.Warn("URL not found",
 				zap.String("short_id", shortID))
-			return "", errors.New("URL not found")
+			return nil, ErrURLNotFound
 		}
 		s.logger.Error("Database error while retrieving URL",
 			zap.Error(err),
 			zap.String("short_id", shortID))
+		return nil, err
+	}
+
+	if err := checkResolvable(&url, time.Now()); err != nil {
+		s.logger.Warn("URL is not resolvable",
+			zap.Error(err),
+			zap.String("short_id", shortID))
+		return &url, err
+	}
+	if url.PasswordProtected() && !unlocked {
+		return &url, ErrPasswordRequired
+	}
+	return &url, nil
+}
+
+// checkResolvable reports why a URL cannot currently be followed, if at all
+func checkResolvable(url *models.URL, now time.Time) error {
+	if url.ExpiresAt != nil && url.ExpiresAt.Before(now) {
+		return ErrURLExpired
+	}
+	if url.IdleExpiresAt != nil && url.IdleExpiresAt.Before(now) {
+		return ErrURLExpired
+	}
+	if url.MaxClicks > 0 && url.ClickCount >= int64(url.MaxClicks) {
+		return ErrURLExpired
+	}
+	if url.NotBefore != nil && url.NotBefore.After(now) {
+		return ErrURLNotYetActive
+	}
+	if url.Status == models.URLStatusPaused {
+		return ErrURLPaused
+	}
+	if url.Status == models.URLStatusHeld {
+		return ErrURLHeld
+	}
+	if url.Status == models.URLStatusDisabled {
+		return ErrURLDisabled
+	}
+	return nil
+}
+
+// ListURLs returns the live URLs, split into those already active and those
+// scheduled to go live later, newest first. Expired URLs are included in the
+// active list.
+func (s *URLService) ListURLs() (active, scheduled []models.URL, err error) {
+	s, span := s.startSpan("ListURLs")
+	defer span.End()
+
+	now := time.Now()
+	if err := s.db.Where("not_before IS NULL OR not_before <= ?", now).
+		Order("created_at DESC").
+		Find(&active).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := s.db.Where("not_before > ?", now).
+		Order("not_before ASC").
+		Find(&scheduled).Error; err != nil {
+		return nil, nil, err
+	}
+	return active, scheduled, nil
+}
+
+// UpdateURL applies update to the URL with the given short ID and returns
+// the updated record
+func (s *URLService) UpdateURL(shortID string, update LinkUpdate) (*models.URL, error) {
+	s, span := s.startSpan("UpdateURL")
+	defer span.End()
+
+	changes := map[string]interface{}{}
+	if update.LongURL != nil {
+		canonical, risk, err := s.checkDestination(*update.LongURL)
+		if err != nil {
+			return nil, err
+		}
+		changes["long_url"] = canonical
+		changes["original_url"] = *update.LongURL
+		changes["url_hash"] = hashURL(canonical)
+		changes["risk_score"] = risk.Score
+		// Map updates bypass the field's JSON serializer
+		signals, err := json.Marshal(risk.Signals)
+		if err != nil {
+			return nil, err
+		}
+		changes["risk_signals"] = string(signals)
+		if risk.Score >= s.risk.holdScore() {
+			changes["status"] = models.URLStatusHeld
+		}
+	}
+	if update.Redirect != nil {
+		if err := update.Redirect.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRedirect, err)
+		}
+		changes["redirect_status_code"] = update.Redirect.StatusCode
+		changes["redirect_cache_max_age"] = update.Redirect.CacheMaxAge
+		changes["redirect_referrer_policy"] = update.Redirect.ReferrerPolicy
+		changes["redirect_no_index"] = update.Redirect.NoIndex
+	}
+
+	if len(changes) > 0 {
+		result := s.db.Model(&models.URL{}).Where("short_id = ?", shortID).Updates(changes)
+		if result.Error != nil {
+			s.logger.Error("Failed to update URL",
+				zap.Error(result.Error),
+				zap.String("short_id", shortID))
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, ErrURLNotFound
+		}
+	}
+
+	var url models.URL
+	if err := s.db.Where("short_id = ?", shortID).First(&url).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrURLNotFound
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Updated URL",
+		zap.String("short_id", shortID),
+		zap.String("long_url", url.LongURL))
+	return &url, nil
+}
+
+// DeleteURL soft-deletes a URL so it can be restored until it is purged
+func (s *URLService) DeleteURL(shortID string) error {
+	s, span := s.startSpan("DeleteURL")
+	defer span.End()
+
+	result := s.db.Where("short_id = ?", shortID).Delete(&models.URL{})
+	if result.Error != nil {
+		s.logger.Error("Failed to delete URL",
+			zap.Error(result.Error),
+			zap.String("short_id", shortID))
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrURLNotFound
+	}
+
+	s.logger.Info("Deleted URL",
+		zap.String("short_id", shortID))
+	return nil
+}
+
+// LinkOwner returns the owner of a URL, including one in the trash. Links
+// created without an API key have no owner.
+func (s *URLService) LinkOwner(shortID string) (string, error) {
+	s, span := s.startSpan("LinkOwner")
+	defer span.End()
+
+	var url models.URL
+	if err := s.db.Unscoped().Select("owner").Where("short_id = ?", shortID).First(&url).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrURLNotFound
+		}
 		return "", err
 	}
+	return url.Owner, nil
+}
+
+// RestoreURL brings a soft-deleted URL back out of the trash
+func (s *URLService) RestoreURL(shortID string) error {
+	s, span := s.startSpan("RestoreURL")
+	defer span.End()
+
+	result := s.db.Unscoped().Model(&models.URL{}).
+		Where("short_id = ? AND deleted_at IS NOT NULL", shortID).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		s.logger.Error("Failed to restore URL",
+			zap.Error(result.Error),
+			zap.String("short_id", shortID))
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrURLNotFound
+	}
+
+	s.logger.Info("Restored URL",
+		zap.String("short_id", shortID))
+	return nil
+}
+
+// SetURLStatus pauses or resumes a URL. Links held for moderation or
+// disabled as unsafe keep their status; ErrURLHeld or ErrURLDisabled is
+// returned for them.
+func (s *URLService) SetURLStatus(shortID, status string) error {
+	s, span := s.startSpan("SetURLStatus")
+	defer span.End()
 
-	// Check if URL has expired
-	if url.ExpiresAt != nil && url.ExpiresAt.Before(time.Now()) {
-		s.logger.Warn("URL has expired",
+	result := s.db.Model(&models.URL{}).
+		Where("short_id = ? AND status NOT IN ?", shortID, []string{models.URLStatusHeld, models.URLStatusDisabled}).
+		Update("status", status)
+	if result.Error != nil {
+		s.logger.Error("Failed to update URL status",
+			zap.Error(result.Error),
 			zap.String("short_id", shortID),
-			zap.Time("expires_at", *url.ExpiresAt))
-		return "", errors.New("URL has expired")
+			zap.String("status", status))
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var url models.URL
+		if err := s.db.Select("status").Where("short_id = ?", shortID).First(&url).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrURLNotFound
+			}
+			return err
+		}
+		if url.Status == models.URLStatusDisabled {
+			return ErrURLDisabled
+		}
+		return ErrURLHeld
 	}
 
-	s.logger.Info("Retrieved long URL",
+	s.logger.Info("Updated URL status",
 		zap.String("short_id", shortID),
-		zap.String("long_url", url.LongURL))
+		zap.String("status", status))
+	return nil
+}
+
+// ApproveURL releases a URL held for moderation. Rejected URLs are deleted
+// with DeleteURL instead.
+func (s *URLService) ApproveURL(shortID string) error {
+	s, span := s.startSpan("ApproveURL")
+	defer span.End()
 
-	return url.LongURL, nil
+	result := s.db.Model(&models.URL{}).
+		Where("short_id = ? AND status = ?", shortID, models.URLStatusHeld).
+		Update("status", models.URLStatusActive)
+	if result.Error != nil {
+		s.logger.Error("Failed to approve URL",
+			zap.Error(result.Error),
+			zap.String("short_id", shortID))
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrURLNotFound
+	}
+
+	s.logger.Info("Approved held URL",
+		zap.String("short_id", shortID))
+	return nil
+}
+
+// ListHeldURLs returns the URLs awaiting moderation, riskiest first
+func (s *URLService) ListHeldURLs() ([]models.URL, error) {
+	s, span := s.startSpan("ListHeldURLs")
+	defer span.End()
+
+	var urls []models.URL
+	if err := s.db.Where("status = ?", models.URLStatusHeld).
+		Order("risk_score DESC, created_at ASC").
+		Find(&urls).Error; err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// ListDeletedURLs returns the URLs currently in the trash, most recently deleted first
+func (s *URLService) ListDeletedURLs() ([]models.URL, error) {
+	s, span := s.startSpan("ListDeletedURLs")
+	defer span.End()
+
+	var urls []models.URL
+	if err := s.db.Unscoped().
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at DESC").
+		Find(&urls).Error; err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// PurgeDeletedURLs permanently removes URLs, and their clicks, unlock
+// attempts and reports, that were deleted longer than gracePeriod ago
+func (s *URLService) PurgeDeletedURLs(gracePeriod time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-gracePeriod)
+	var purged int64
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Unscoped().Model(&models.URL{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Where("url_id IN ?", ids).Delete(&models.Click{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("url_id IN ?", ids).Delete(&models.UnlockAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("url_id IN ?", ids).Delete(&models.Report{}).Error; err != nil {
+			return err
+		}
+		// Audit entries outlive the link, found by short ID alone
+		if err := tx.Model(&models.AuditEntry{}).
+			Where("url_id IN ?", ids).
+			Update("url_id", 0).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.URL{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to purge deleted URLs",
+			zap.Error(err))
+		return 0, err
+	}
+
+	if purged > 0 {
+		s.logger.Info("Purged deleted URLs",
+			zap.Int64("count", purged),
+			zap.Time("deleted_before", cutoff))
+	}
+	return purged, nil
+}
+
+// StartPurgeJob periodically purges deleted URLs until ctx is cancelled
+func (s *URLService) StartPurgeJob(ctx context.Context, interval, gracePeriod time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.PurgeDeletedURLs(gracePeriod)
+			}
+		}
+	}()
+}
+
+// reputationTimeout bounds the reputation check of a new destination
+const reputationTimeout = 5 * time.Second
+
+// rescanBatchSize is how many links are looked up at once when rescanning
+const rescanBatchSize = 500
+
+// auditActorRescan is the audit actor for links disabled by a rescan
+const auditActorRescan = "reputation_scan"
+
+// DisabledReasonUnsafe is the disabled reason of links taken down by a rescan
+const DisabledReasonUnsafe = "unsafe"
+
+// RescanURLs checks the destinations of all links that are not already
+// disabled with the reputation checker. Links found to be unsafe are
+// disabled and an audit entry is recorded for each. It returns the number
+// of links disabled.
+func (s *URLService) RescanURLs(ctx context.Context) (int, error) {
+	if s.reputation == nil {
+		return 0, nil
+	}
+
+	disabled := 0
+	var lastID uint
+	for {
+		var urls []models.URL
+		if err := s.db.Select("id", "short_id", "long_url").
+			Where("id > ? AND status <> ?", lastID, models.URLStatusDisabled).
+			Order("id").
+			Limit(rescanBatchSize).
+			Find(&urls).Error; err != nil {
+			return disabled, err
+		}
+		if len(urls) == 0 {
+			break
+		}
+		lastID = urls[len(urls)-1].ID
+
+		destinations := make([]string, 0, len(urls))
+		seen := make(map[string]bool)
+		for _, url := range urls {
+			if !seen[url.LongURL] {
+				seen[url.LongURL] = true
+				destinations = append(destinations, url.LongURL)
+			}
+		}
+		verdicts, err := s.reputation.CheckURLs(ctx, destinations)
+		if err != nil {
+			s.logger.Error("Reputation rescan failed",
+				zap.Error(err),
+				zap.Int("disabled", disabled))
+			return disabled, err
+		}
+
+		for _, url := range urls {
+			verdict := verdicts[url.LongURL]
+			if !verdict.Malicious {
+				continue
+			}
+			detail := "flagged as unsafe: " + strings.Join(verdict.Threats, ", ")
+			if err := s.db.Transaction(func(tx *gorm.DB) error {
+				return s.disableURL(tx, url, DisabledReasonUnsafe, auditActorRescan, detail)
+			}); err != nil {
+				return disabled, err
+			}
+			disabled++
+		}
+	}
+
+	s.logger.Info("Finished reputation rescan",
+		zap.Int("disabled", disabled))
+	return disabled, nil
+}
+
+// disableURL takes a link down within tx and records who did it and why
+func (s *URLService) disableURL(tx *gorm.DB, url models.URL, reason, actor, detail string) error {
+	err := tx.Model(&models.URL{}).Where("id = ?", url.ID).Updates(map[string]interface{}{
+		"status":          models.URLStatusDisabled,
+		"disabled_reason": reason,
+	}).Error
+	if err == nil {
+		err = recordAudit(tx, url, models.AuditLinkDisabled, actor, detail)
+	}
+	if err != nil {
+		s.logger.Error("Failed to disable URL",
+			zap.Error(err),
+			zap.String("short_id", url.ShortID))
+		return err
+	}
+
+	s.logger.Warn("Disabled URL",
+		zap.String("short_id", url.ShortID),
+		zap.String("long_url", url.LongURL),
+		zap.String("actor", actor),
+		zap.String("detail", detail))
+	return nil
+}
+
+// recordAudit adds an audit entry for an action on url
+func recordAudit(tx *gorm.DB, url models.URL, action, actor, detail string) error {
+	return tx.Create(&models.AuditEntry{
+		URLID:   url.ID,
+		ShortID: url.ShortID,
+		Action:  action,
+		Actor:   actor,
+		Detail:  detail,
+	}).Error
+}
+
+// AuditLog returns the audit entries of a link, newest first
+func (s *URLService) AuditLog(shortID string) ([]models.AuditEntry, error) {
+	s, span := s.startSpan("AuditLog")
+	defer span.End()
+
+	var entries []models.AuditEntry
+	if err := s.db.Where("short_id = ?", shortID).
+		Order("id DESC").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// StartRescanJob periodically rescans link destinations until ctx is
+// cancelled. An interval of zero or less disables it.
+func (s *URLService) StartRescanJob(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.RescanURLs(ctx)
+			}
+		}
+	}()
 }
 
 // validateURL checks if the given URL is valid
@@ -157,9 +1257,9 @@ func (s *URLService) CheckGeoFencing(r *http.Request) (string, bool) {
 	// In a real implementation, you would use a geo-IP database
 	// For testing, we'll use a simple mapping
 	ipToCountry := map[string]string{
-		"8.8.8.8":         "US",
-		"1.1.1.1":         "AU",
-		"185.143.223.12":  "RU",
+		"8.8.8.8":        "US",
+		"1.1.1.1":        "AU",
+		"185.143.223.12": "RU",
 	}
 
 	ip := r.RemoteAddr
@@ -176,38 +1276,10 @@ func (s *URLService) CheckGeoFencing(r *http.Request) (string, bool) {
 	return country, !s.restrictedCountries[country]
 }
 
-// CheckRateLimit checks if the request is within rate limits
-func (s *URLService) CheckRateLimit(r *http.Request) bool {
-	ip := r.RemoteAddr
-	now := time.Now()
-
-	// Clean up old timestamps
-	if timestamps, exists := s.rateLimiter[ip]; exists {
-		var validTimestamps []time.Time
-		for _, ts := range timestamps {
-			if now.Sub(ts) < time.Minute {
-				validTimestamps = append(validTimestamps, ts)
-			}
-		}
-		s.rateLimiter[ip] = validTimestamps
-	}
-
-	// Check if rate limit exceeded
-	if timestamps, exists := s.rateLimiter[ip]; exists {
-		if len(timestamps) >= 60 { // 60 requests per minute
-			return false
-		}
-	}
-
-	// Add new timestamp
-	s.rateLimiter[ip] = append(s.rateLimiter[ip], now)
-	return true
-}
-
 // ForceExpireURL forces a URL to expire (for testing)
 func (s *URLService) ForceExpireURL(shortID string) error {
 	return s.db.Model(&models.URL{}).
 		Where("short_id = ?", shortID).
 		Update("expires_at", time.Now().Add(-time.Hour)).
 		Error
-} 
\ No newline at end of file
+}
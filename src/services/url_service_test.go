@@ -0,0 +1,781 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	applog "github.com/yourusername/urlshortener/src/logger"
+	"github.com/yourusername/urlshortener/src/models"
+	"github.com/yourusername/urlshortener/src/ratelimit"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a migrated SQLite database in a temporary directory
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.URL{}, &models.Click{}, &models.UnlockAttempt{}, &models.AuditEntry{}, &models.Report{}, &models.BannedKey{}))
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
+
+func TestURLLifecycle(t *testing.T) {
+	service := NewURLService(newTestDB(t), URLServiceConfig{})
+
+	url, err := service.CreateShortURL("https://example.com/doc", LinkOptions{})
+	require.NoError(t, err)
+
+	// Paused links do not resolve
+	require.NoError(t, service.SetURLStatus(url.ShortID, models.URLStatusPaused))
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.ErrorIs(t, err, ErrURLPaused)
+
+	require.NoError(t, service.SetURLStatus(url.ShortID, models.URLStatusActive))
+	resolved, err := service.ResolveURL(url.ShortID, false)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/doc", resolved.LongURL)
+
+	// Deleted links do not resolve but show up in the trash
+	require.NoError(t, service.DeleteURL(url.ShortID))
+	owner, err := service.LinkOwner(url.ShortID)
+	require.NoError(t, err)
+	assert.Empty(t, owner, "links created without a key have no owner")
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	trash, err := service.ListDeletedURLs()
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, url.ShortID, trash[0].ShortID)
+
+	// Restored links resolve again
+	require.NoError(t, service.RestoreURL(url.ShortID))
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, service.RestoreURL(url.ShortID), ErrURLNotFound)
+	_, err = service.LinkOwner("missing")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+}
+
+func TestPurgeDeletedURLs(t *testing.T) {
+	db := newTestDB(t)
+	service := NewURLService(db, URLServiceConfig{})
+
+	old, err := service.CreateShortURL("https://example.com/old", LinkOptions{})
+	require.NoError(t, err)
+	recent, err := service.CreateShortURL("https://example.com/recent", LinkOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Create(&models.Click{URLID: old.ID, IPAddress: "1.1.1.1", UserAgent: "test", Country: "AU", Device: "desktop", CreatedAt: time.Now()}).Error)
+	_, err = service.ReportURL(old.ShortID, "spam", "", "1.1.1.1")
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.AuditEntry{URLID: old.ID, ShortID: old.ShortID, Action: models.AuditLinkDisabled, Actor: "admin"}).Error)
+
+	require.NoError(t, service.DeleteURL(old.ShortID))
+	require.NoError(t, service.DeleteURL(recent.ShortID))
+	require.NoError(t, db.Unscoped().Model(&models.URL{}).
+		Where("id = ?", old.ID).
+		Update("deleted_at", time.Now().Add(-48*time.Hour)).Error)
+
+	purged, err := service.PurgeDeletedURLs(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	var urlCount, clickCount int64
+	db.Unscoped().Model(&models.URL{}).Where("id = ?", old.ID).Count(&urlCount)
+	db.Model(&models.Click{}).Where("url_id = ?", old.ID).Count(&clickCount)
+	assert.Zero(t, urlCount)
+	assert.Zero(t, clickCount)
+
+	// Reports go with the link, but its audit trail is kept
+	var reportCount int64
+	db.Model(&models.Report{}).Where("url_id = ?", old.ID).Count(&reportCount)
+	assert.Zero(t, reportCount)
+	entries, err := service.AuditLog(old.ShortID)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	for _, entry := range entries {
+		assert.Zero(t, entry.URLID)
+	}
+
+	// The recently deleted link is still restorable
+	assert.NoError(t, service.RestoreURL(recent.ShortID))
+}
+
+func TestExpiryPolicy(t *testing.T) {
+	service := NewURLService(newTestDB(t), URLServiceConfig{
+		Expiry: ExpiryPolicy{DefaultTTL: 24 * time.Hour, MaxTTL: 48 * time.Hour},
+	})
+
+	// The default applies when no expiry is requested
+	url, err := service.CreateShortURL("https://example.com/default", LinkOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, url.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *url.ExpiresAt, time.Minute)
+
+	// Requests beyond the maximum or in the past are rejected
+	tooLate := time.Now().Add(72 * time.Hour)
+	_, err = service.CreateShortURL("https://example.com/late", LinkOptions{ExpiresAt: &tooLate})
+	assert.ErrorIs(t, err, ErrExpiryTooLong)
+
+	past := time.Now().Add(-time.Hour)
+	_, err = service.CreateShortURL("https://example.com/past", LinkOptions{ExpiresAt: &past})
+	assert.ErrorIs(t, err, ErrExpiryInPast)
+}
+
+func TestMaxClicksUnderConcurrency(t *testing.T) {
+	service := NewURLService(newTestDB(t), URLServiceConfig{})
+
+	url, err := service.CreateShortURL("https://example.com/limited", LinkOptions{MaxClicks: 5})
+	require.NoError(t, err)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.ResolveURL(url.ShortID, false)
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrURLExpired) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, succeeded)
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.ErrorIs(t, err, ErrURLExpired)
+}
+
+func TestExpireAfterInactive(t *testing.T) {
+	db := newTestDB(t)
+	service := NewURLService(db, URLServiceConfig{})
+
+	url, err := service.CreateShortURL("https://example.com/idle", LinkOptions{ExpireAfterInactive: 7})
+	require.NoError(t, err)
+
+	_, err = service.ResolveURL(url.ShortID, false)
+	require.NoError(t, err)
+
+	// Pretend the last click was more than a week ago
+	require.NoError(t, db.Model(&models.URL{}).
+		Where("id = ?", url.ID).
+		Update("idle_expires_at", time.Now().Add(-time.Minute)).Error)
+
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.ErrorIs(t, err, ErrURLExpired)
+}
+
+func TestPasswordProtectedURL(t *testing.T) {
+	db := newTestDB(t)
+	limiter := ratelimit.NewMemoryLimiter()
+	policy := UnlockPolicy{CookieTTL: time.Minute, MaxFailures: 2, FailureWindow: time.Minute}
+	service := NewURLService(db, URLServiceConfig{Unlock: policy, Attempts: limiter})
+
+	url, err := service.CreateShortURL("https://example.com/private", LinkOptions{Password: "hunter2"})
+	require.NoError(t, err)
+	assert.NotEqual(t, "hunter2", url.PasswordHash)
+
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+
+	// Failed attempts are recorded and limited per IP and link
+	assert.ErrorIs(t, service.UnlockURL(url.ShortID, "wrong", "10.0.0.1", "test"), ErrPasswordIncorrect)
+	assert.ErrorIs(t, service.UnlockURL(url.ShortID, "wrong", "10.0.0.1", "test"), ErrPasswordIncorrect)
+	assert.ErrorIs(t, service.UnlockURL(url.ShortID, "hunter2", "10.0.0.1", "test"), ErrTooManyAttempts)
+	other, err := service.CreateShortURL("https://example.com/other", LinkOptions{Password: "hunter2"})
+	require.NoError(t, err)
+	require.NoError(t, service.UnlockURL(other.ShortID, "hunter2", "10.0.0.1", "test"))
+
+	// Instances sharing a limiter share the count
+	replica := NewURLService(db, URLServiceConfig{Unlock: policy, Attempts: limiter})
+	assert.ErrorIs(t, replica.UnlockURL(url.ShortID, "hunter2", "10.0.0.1", "test"), ErrTooManyAttempts)
+
+	var attempts int64
+	db.Model(&models.UnlockAttempt{}).Where("url_id = ?", url.ID).Count(&attempts)
+	assert.Equal(t, int64(2), attempts)
+
+	// Correct passwords are not counted
+	for i := 0; i < 3; i++ {
+		require.NoError(t, service.UnlockURL(url.ShortID, "hunter2", "10.0.0.3", "test"))
+	}
+
+	// Another IP can still unlock with the right password
+	require.NoError(t, service.UnlockURL(url.ShortID, "hunter2", "10.0.0.2", "test"))
+	token, _ := service.IssueUnlockToken(url.ShortID)
+	assert.True(t, service.VerifyUnlockToken(url.ShortID, token))
+	assert.False(t, service.VerifyUnlockToken("other", token))
+	assert.False(t, service.VerifyUnlockToken(url.ShortID, token+"0"))
+
+	resolved, err := service.ResolveURL(url.ShortID, true)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/private", resolved.LongURL)
+}
+
+func TestScheduledURL(t *testing.T) {
+	db := newTestDB(t)
+	service := NewURLService(db, URLServiceConfig{})
+
+	goLive := time.Now().Add(time.Hour)
+	url, err := service.CreateShortURL("https://example.com/launch", LinkOptions{NotBefore: &goLive})
+	require.NoError(t, err)
+
+	link, err := service.ResolveURL(url.ShortID, false)
+	assert.ErrorIs(t, err, ErrURLNotYetActive)
+	require.NotNil(t, link)
+	assert.Zero(t, link.ClickCount)
+
+	active, scheduled, err := service.ListURLs()
+	require.NoError(t, err)
+	assert.Empty(t, active)
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, url.ShortID, scheduled[0].ShortID)
+
+	// Once live the link resolves normally
+	require.NoError(t, db.Model(&models.URL{}).
+		Where("id = ?", url.ID).
+		Update("not_before", time.Now().Add(-time.Minute)).Error)
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.NoError(t, err)
+
+	// A window that closes before it opens is rejected
+	expires := goLive.Add(-time.Minute)
+	_, err = service.CreateShortURL("https://example.com/bad", LinkOptions{NotBefore: &goLive, ExpiresAt: &expires})
+	assert.ErrorIs(t, err, ErrInvalidWindow)
+}
+
+func TestUpdateURL(t *testing.T) {
+	service := NewURLService(newTestDB(t), URLServiceConfig{})
+
+	url, err := service.CreateShortURL("https://example.com/v1", LinkOptions{})
+	require.NoError(t, err)
+	_, err = service.ResolveURL(url.ShortID, false)
+	require.NoError(t, err)
+
+	maxAge := 60
+	longURL := "https://example.com/v2"
+	updated, err := service.UpdateURL(url.ShortID, LinkUpdate{
+		LongURL:  &longURL,
+		Redirect: &models.RedirectProfile{StatusCode: 307, CacheMaxAge: &maxAge},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, longURL, updated.LongURL)
+	assert.Equal(t, 307, updated.Redirect.StatusCode)
+	assert.Equal(t, 60, *updated.Redirect.CacheMaxAge)
+	assert.Equal(t, int64(1), updated.ClickCount)
+
+	_, err = service.UpdateURL(url.ShortID, LinkUpdate{Redirect: &models.RedirectProfile{StatusCode: 200}})
+	assert.ErrorIs(t, err, ErrInvalidRedirect)
+
+	_, err = service.UpdateURL("missing", LinkUpdate{LongURL: &longURL})
+	assert.ErrorIs(t, err, ErrURLNotFound)
+}
+
+func TestInspectURLDoesNotCountClicks(t *testing.T) {
+	service := NewURLService(newTestDB(t), URLServiceConfig{})
+
+	url, err := service.CreateShortURL("https://example.com/peek", LinkOptions{MaxClicks: 1})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		inspected, err := service.InspectURL(url.ShortID, false)
+		require.NoError(t, err)
+		assert.Zero(t, inspected.ClickCount)
+	}
+
+	// The single allowed click is still available
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.NoError(t, err)
+}
+
+func TestRecordClickSource(t *testing.T) {
+	db := newTestDB(t)
+	urlService := NewURLService(db, URLServiceConfig{})
+	analytics := NewAnalyticsService(db, nil)
+
+	url, err := urlService.CreateShortURL("https://example.com/print", LinkOptions{})
+	require.NoError(t, err)
+
+	for _, target := range []string{"/x?src=qr", "/x?src=QR", "/x", "/x?src=<script>"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, analytics.RecordClick(url.ID, req))
+	}
+
+	var sources []string
+	require.NoError(t, db.Model(&models.Click{}).Order("id").Pluck("source", &sources).Error)
+	assert.Equal(t, []string{"qr", "qr", "", ""}, sources)
+
+	stats, err := analytics.GetAnalytics(url.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, stats["total_clicks"])
+}
+
+func TestCreateShortURLs(t *testing.T) {
+	db := newTestDB(t)
+	service := NewURLService(db, URLServiceConfig{})
+
+	_, err := service.CreateShortURL("https://example.com/existing", LinkOptions{Alias: "taken"})
+	require.NoError(t, err)
+
+	reqs := []LinkRequest{
+		{LongURL: "https://example.com/a", Options: LinkOptions{Alias: "launch", Tags: []string{" cms ", "cms", "spring"}}},
+		{LongURL: "https://example.com/b", Options: LinkOptions{Alias: "taken"}},
+		{LongURL: "https://example.com/c", Options: LinkOptions{Alias: "links"}},
+		{LongURL: "https://example.com/d"},
+	}
+
+	t.Run("Atomic", func(t *testing.T) {
+		results, err := service.CreateShortURLs(reqs, true)
+		require.NoError(t, err)
+		// The invalid alias fails the batch before anything is inserted
+		assert.ErrorIs(t, results[0].Err, ErrBatchAborted)
+		assert.ErrorIs(t, results[1].Err, ErrBatchAborted)
+		assert.ErrorIs(t, results[2].Err, ErrInvalidAlias)
+		assert.ErrorIs(t, results[3].Err, ErrBatchAborted)
+
+		var count int64
+		require.NoError(t, db.Model(&models.URL{}).Count(&count).Error)
+		assert.EqualValues(t, 1, count)
+	})
+
+	t.Run("Best effort", func(t *testing.T) {
+		results, err := service.CreateShortURLs(reqs, false)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.Equal(t, "launch", results[0].URL.ShortID)
+		assert.ErrorIs(t, results[1].Err, ErrAliasTaken)
+		assert.ErrorIs(t, results[2].Err, ErrInvalidAlias)
+		require.NoError(t, results[3].Err)
+
+		stored, err := service.InspectURL("launch", false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"cms", "spring"}, stored.Tags)
+	})
+
+	t.Run("Duplicate alias within a batch", func(t *testing.T) {
+		results, err := service.CreateShortURLs([]LinkRequest{
+			{LongURL: "https://example.com/e", Options: LinkOptions{Alias: "twice"}},
+			{LongURL: "https://example.com/f", Options: LinkOptions{Alias: "twice"}},
+		}, true)
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, ErrBatchAborted)
+		assert.ErrorIs(t, results[1].Err, ErrAliasTaken)
+		_, err = service.InspectURL("twice", false)
+		assert.ErrorIs(t, err, ErrURLNotFound)
+	})
+}
+
+func TestReuseURL(t *testing.T) {
+	service := NewURLService(newTestDB(t), URLServiceConfig{})
+	alice, bob := KeyOwner("alice-key"), KeyOwner("bob-key")
+
+	first, err := service.CreateShortURL("https://Example.com/page", LinkOptions{Owner: alice, Reuse: true})
+	require.NoError(t, err)
+
+	// Same destination and owner: the existing link comes back
+	again, err := service.CreateShortURL("https://example.com/page", LinkOptions{Owner: alice, Reuse: true})
+	require.NoError(t, err)
+	assert.Equal(t, first.ShortID, again.ShortID)
+
+	// Without reuse, for another owner, or with a password a new link is made
+	for _, opts := range []LinkOptions{
+		{Owner: alice},
+		{Owner: bob, Reuse: true},
+		{Owner: alice, Reuse: true, Password: "secret"},
+	} {
+		other, err := service.CreateShortURL("https://example.com/page", opts)
+		require.NoError(t, err)
+		assert.NotEqual(t, first.ShortID, other.ShortID)
+	}
+
+	// Paused links are not reused
+	require.NoError(t, service.SetURLStatus(first.ShortID, models.URLStatusPaused))
+	fresh, err := service.CreateShortURL("https://example.com/page", LinkOptions{Owner: alice, Reuse: true})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ShortID, fresh.ShortID)
+
+	// Reuse also applies between items of one batch
+	results, err := service.CreateShortURLs([]LinkRequest{
+		{LongURL: "https://example.com/batch", Options: LinkOptions{Owner: bob, Reuse: true}},
+		{LongURL: "https://example.com/batch", Options: LinkOptions{Owner: bob, Reuse: true}},
+	}, true)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, results[0].URL.ShortID, results[1].URL.ShortID)
+}
+
+func TestCanonicalURLs(t *testing.T) {
+	service := NewURLService(newTestDB(t), URLServiceConfig{
+		Canonical: CanonicalOptions{StripTracking: true},
+	})
+
+	url, err := service.CreateShortURL("HTTP://Example.com:80/a/../b?utm_source=mail", LinkOptions{Reuse: true})
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/b", url.LongURL)
+	assert.Equal(t, "HTTP://Example.com:80/a/../b?utm_source=mail", url.OriginalURL)
+
+	// Spellings of the same destination share a link
+	same, err := service.CreateShortURL("http://example.com/b", LinkOptions{Reuse: true})
+	require.NoError(t, err)
+	assert.Equal(t, url.ShortID, same.ShortID)
+
+	_, err = service.CreateShortURL("not a url", LinkOptions{})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+
+	target := "https://Example.com/c/./d"
+	updated, err := service.UpdateURL(url.ShortID, LinkUpdate{LongURL: &target})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/c/d", updated.LongURL)
+	assert.Equal(t, target, updated.OriginalURL)
+}
+
+func TestDestinationPolicyOnLinks(t *testing.T) {
+	service := NewURLService(newTestDB(t), URLServiceConfig{
+		Destination: DestinationPolicy{OwnHosts: []string{"sho.rt"}},
+	})
+
+	_, err := service.CreateShortURL("http://169.254.169.254/latest", LinkOptions{})
+	assert.ErrorIs(t, err, ErrDestinationRejected)
+	_, err = service.CreateShortURL("https://Sho.rt:443/abc", LinkOptions{})
+	assert.ErrorIs(t, err, ErrDestinationRejected)
+	_, err = service.CreateShortURL("https://example.com/", LinkOptions{ExpiredRedirectURL: "http://localhost/"})
+	assert.ErrorIs(t, err, ErrDestinationRejected)
+
+	url, err := service.CreateShortURL("https://example.com/", LinkOptions{PrelaunchURL: "/coming-soon"})
+	require.NoError(t, err)
+
+	// Relative fallbacks must be paths on this service
+	for _, fallback := range []string{"//evil.com/x", `/\evil.com/x`, "coming-soon"} {
+		_, err = service.CreateShortURL("https://example.com/", LinkOptions{PrelaunchURL: fallback})
+		assert.ErrorIs(t, err, ErrInvalidURL, fallback)
+		_, err = service.CreateShortURL("https://example.com/", LinkOptions{ExpiredRedirectURL: fallback})
+		assert.ErrorIs(t, err, ErrInvalidURL, fallback)
+	}
+
+	target := "http://192.168.0.1/admin"
+	_, err = service.UpdateURL(url.ShortID, LinkUpdate{LongURL: &target})
+	var violation *PolicyViolation
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, ReasonPrivateNetwork, violation.Reason)
+}
+
+func TestRiskyURLs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("evil.example\n"), 0o644))
+	blocklist, err := LoadBlocklist(path)
+	require.NoError(t, err)
+	service := NewURLService(newTestDB(t), URLServiceConfig{
+		Risk: RiskPolicy{Blocklist: blocklist},
+	})
+
+	// Blocklisted and very risky destinations are refused
+	var violation *PolicyViolation
+	_, err = service.CreateShortURL("https://www.evil.example/", LinkOptions{})
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, ReasonBlockedHost, violation.Reason)
+	_, err = service.CreateShortURL("https://g00gle.example/", LinkOptions{})
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, ReasonHighRisk, violation.Reason)
+
+	// Fallbacks are checked like destinations
+	_, err = service.CreateShortURL("https://example.com/", LinkOptions{ExpiredRedirectURL: "https://blocked.evil.example/"})
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, ReasonBlockedHost, violation.Reason)
+	fallback, err := service.CreateShortURL("https://example.com/", LinkOptions{PrelaunchURL: "https://paypal-login.example/"})
+	require.NoError(t, err)
+	assert.Equal(t, models.URLStatusHeld, fallback.Status)
+	assert.Equal(t, []string{SignalBrandSpoof}, fallback.RiskSignals)
+	require.NoError(t, service.DeleteURL(fallback.ShortID))
+
+	// Suspicious ones are held until approved
+	url, err := service.CreateShortURL("https://paypal-login.example/", LinkOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, models.URLStatusHeld, url.Status)
+	assert.Equal(t, []string{SignalBrandSpoof}, url.RiskSignals)
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.ErrorIs(t, err, ErrURLHeld)
+	assert.ErrorIs(t, service.SetURLStatus(url.ShortID, models.URLStatusActive), ErrURLHeld)
+
+	held, err := service.ListHeldURLs()
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+	assert.Equal(t, 60, held[0].RiskScore)
+
+	require.NoError(t, service.ApproveURL(url.ShortID))
+	_, err = service.ResolveURL(url.ShortID, false)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, service.ApproveURL(url.ShortID), ErrURLNotFound)
+
+	// Changing the destination is scored too
+	target := "http://93.184.216.34/"
+	updated, err := service.UpdateURL(url.ShortID, LinkUpdate{LongURL: &target})
+	require.NoError(t, err)
+	assert.Equal(t, models.URLStatusHeld, updated.Status)
+	assert.Equal(t, []string{SignalIPLiteral}, updated.RiskSignals)
+
+	target = "https://evil.example/"
+	_, err = service.UpdateURL(url.ShortID, LinkUpdate{LongURL: &target})
+	assert.ErrorIs(t, err, ErrDestinationRejected)
+}
+
+func TestReputationChecks(t *testing.T) {
+	threats := map[string]string{"https://malware.example/": "MALWARE"}
+	fake := &fakeSafeBrowsing{threats: threats}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	db := newTestDB(t)
+	service := NewURLService(db, URLServiceConfig{
+		Reputation: NewSafeBrowsingClient(SafeBrowsingConfig{Endpoint: server.URL}),
+	})
+
+	// Flagged destinations are refused up front
+	var violation *PolicyViolation
+	_, err := service.CreateShortURL("https://malware.example/", LinkOptions{})
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, ReasonUnsafe, violation.Reason)
+
+	clean, err := service.CreateShortURL("https://example.com/", LinkOptions{})
+	require.NoError(t, err)
+	turned, err := service.CreateShortURL("https://phish.example/", LinkOptions{})
+	require.NoError(t, err)
+	require.NoError(t, service.SetURLStatus(turned.ShortID, models.URLStatusPaused))
+
+	// Destinations that turn bad later are disabled by the rescan
+	fake.mu.Lock()
+	threats["https://phish.example/"] = "SOCIAL_ENGINEERING"
+	fake.mu.Unlock()
+	disabled, err := service.RescanURLs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, disabled)
+
+	_, err = service.ResolveURL(turned.ShortID, false)
+	assert.ErrorIs(t, err, ErrURLDisabled)
+	_, err = service.ResolveURL(clean.ShortID, false)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, service.SetURLStatus(turned.ShortID, models.URLStatusActive), ErrURLDisabled)
+
+	entries, err := service.AuditLog(turned.ShortID)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, models.AuditLinkDisabled, entries[0].Action)
+	assert.Equal(t, "reputation_scan", entries[0].Actor)
+	assert.Equal(t, "flagged as unsafe: SOCIAL_ENGINEERING", entries[0].Detail)
+
+	// Disabled links are not scanned again
+	disabled, err = service.RescanURLs(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, disabled)
+
+	// An unreachable checker does not block link creation
+	server.Close()
+	_, err = service.CreateShortURL("https://example.org/", LinkOptions{})
+	assert.NoError(t, err)
+
+	// A zero interval disables the rescan job
+	assert.NotPanics(t, func() { service.StartRescanJob(context.Background(), 0) })
+}
+
+func TestReports(t *testing.T) {
+	service := NewURLService(newTestDB(t), URLServiceConfig{
+		Report: ReportPolicy{MaxPerIP: 2, Window: time.Hour},
+	})
+	owner := KeyOwner("spammer-key")
+	first, err := service.CreateShortURL("https://spam.example/a", LinkOptions{Owner: owner})
+	require.NoError(t, err)
+	second, err := service.CreateShortURL("https://spam.example/b", LinkOptions{Owner: owner})
+	require.NoError(t, err)
+	anonymous, err := service.CreateShortURL("https://example.com/", LinkOptions{})
+	require.NoError(t, err)
+
+	_, err = service.ReportURL(first.ShortID, "rude", "", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidReportReason)
+	_, err = service.ReportURL("missing", "spam", "", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	// Reports are rate limited per IP
+	spam, err := service.ReportURL(first.ShortID, "spam", " unsolicited ", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "unsolicited", spam.Details)
+	again, err := service.ReportURL(first.ShortID, "Phishing", "", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "phishing", again.Reason)
+	_, err = service.ReportURL(first.ShortID, "spam", "", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrTooManyReports)
+	other, err := service.ReportURL(anonymous.ShortID, "other", "", "192.0.2.2")
+	require.NoError(t, err)
+
+	triaged, err := service.TriageReport(spam.ID, "checking")
+	require.NoError(t, err)
+	assert.Equal(t, models.ReportStatusTriaged, triaged.Status)
+	queue, err := service.ListReports("")
+	require.NoError(t, err)
+	assert.Len(t, queue, 3)
+	open, err := service.ListReports(models.ReportStatusOpen)
+	require.NoError(t, err)
+	assert.Len(t, open, 2)
+
+	// Links without an owner cannot have their key banned
+	_, err = service.ResolveReport(other.ID, ReportActionBan, "admin", "")
+	assert.ErrorIs(t, err, ErrNoOwner)
+	_, err = service.ResolveReport(other.ID, "shrug", "admin", "")
+	assert.ErrorIs(t, err, ErrInvalidAction)
+	_, err = service.ResolveReport(other.ID, ReportActionDismiss, "admin", "")
+	require.NoError(t, err)
+	_, err = service.ResolveURL(anonymous.ShortID, false)
+	assert.NoError(t, err)
+
+	// Banning closes every report on the link, disables all of the key's
+	// links and stops the key from creating more
+	resolved, err := service.ResolveReport(spam.ID, ReportActionBan, "admin", "repeat offender")
+	require.NoError(t, err)
+	assert.Equal(t, models.ReportStatusResolved, resolved.Status)
+	assert.Equal(t, ReportActionBan, resolved.Resolution)
+	queue, err = service.ListReports("")
+	require.NoError(t, err)
+	assert.Empty(t, queue)
+	_, err = service.ResolveReport(again.ID, ReportActionDisable, "admin", "")
+	assert.ErrorIs(t, err, ErrReportResolved)
+
+	for _, shortID := range []string{first.ShortID, second.ShortID} {
+		link, err := service.ResolveURL(shortID, false)
+		assert.ErrorIs(t, err, ErrURLDisabled)
+		require.NotNil(t, link)
+		assert.Equal(t, "spam", link.DisabledReason)
+	}
+	_, err = service.CreateShortURL("https://spam.example/c", LinkOptions{Owner: owner})
+	assert.ErrorIs(t, err, ErrOwnerBanned)
+
+	entries, err := service.AuditLog(first.ShortID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, models.AuditLinkDisabled, entries[0].Action)
+	assert.Equal(t, "admin", entries[0].Actor)
+	assert.Equal(t, fmt.Sprintf("report #%d: spam (repeat offender)", spam.ID), entries[0].Detail)
+	assert.Equal(t, models.AuditKeyBanned, entries[1].Action)
+
+	// Deleting moves the link to the trash
+	report, err := service.ReportURL(anonymous.ShortID, "illegal", "", "192.0.2.3")
+	require.NoError(t, err)
+	_, err = service.ResolveReport(report.ID, ReportActionDelete, "admin", "")
+	require.NoError(t, err)
+	_, err = service.ResolveURL(anonymous.ShortID, false)
+	assert.ErrorIs(t, err, ErrURLNotFound)
+}
+
+// fixedQuota gives every owner the same quota, shared within groups
+type fixedQuota struct {
+	limit  int
+	groups map[string][]string
+}
+
+func (q fixedQuota) MonthlyLinks(owner string) (int, []string) {
+	return q.limit, q.groups[owner]
+}
+
+func TestLinkQuota(t *testing.T) {
+	db := newTestDB(t)
+	team := []string{KeyOwner("alice"), KeyOwner("bob")}
+	service := NewURLService(db, URLServiceConfig{
+		Quota: fixedQuota{limit: 2, groups: map[string][]string{team[0]: team, team[1]: team}},
+	})
+
+	alice, err := service.CreateShortURL("https://example.com/1", LinkOptions{Owner: team[0]})
+	require.NoError(t, err)
+	_, err = service.CreateShortURL("https://example.com/2", LinkOptions{Owner: team[1]})
+	require.NoError(t, err)
+
+	usage, err := service.LinkQuota(team[0])
+	require.NoError(t, err)
+	assert.Equal(t, 2, usage.Limit)
+	assert.Equal(t, 2, usage.Used)
+	assert.Zero(t, usage.Remaining)
+	assert.Equal(t, 1, usage.ResetsAt.Day())
+	assert.True(t, usage.ResetsAt.After(time.Now()))
+
+	// The team shares the quota, and deleting links does not give it back
+	require.NoError(t, service.DeleteURL(alice.ShortID))
+	_, err = service.CreateShortURL("https://example.com/3", LinkOptions{Owner: team[1]})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	results, err := service.CreateShortURLs([]LinkRequest{
+		{LongURL: "https://example.com/4", Options: LinkOptions{Owner: team[0]}},
+	}, false)
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, ErrQuotaExceeded)
+
+	// Reusing a link does not use up quota
+	reused, err := service.CreateShortURL("https://example.com/2", LinkOptions{Owner: team[1], Reuse: true})
+	require.NoError(t, err)
+	assert.NotEmpty(t, reused.ShortID)
+
+	// Links from last month and from other keys do not count
+	require.NoError(t, db.Unscoped().Model(&models.URL{}).Where("owner = ?", team[1]).
+		Update("created_at", time.Now().AddDate(0, -1, -1)).Error)
+	_, err = service.CreateShortURL("https://example.com/5", LinkOptions{Owner: team[1]})
+	assert.NoError(t, err)
+	_, err = service.CreateShortURL("https://example.com/6", LinkOptions{Owner: KeyOwner("carol")})
+	assert.NoError(t, err)
+	_, err = service.CreateShortURL("https://example.com/7", LinkOptions{})
+	assert.NoError(t, err)
+}
+
+func TestLogsCarryRequestID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	service := NewURLService(newTestDB(t), URLServiceConfig{})
+	service.logger = zap.New(core)
+
+	scoped := service.WithContext(applog.WithRequestID(context.Background(), "req-42"))
+	url, err := scoped.CreateShortURL("https://example.com/logged", LinkOptions{})
+	require.NoError(t, err)
+	_, err = scoped.ResolveURL(url.ShortID, false)
+	require.NoError(t, err)
+
+	require.NotZero(t, logs.Len())
+	for _, entry := range logs.All() {
+		assert.Equal(t, "req-42", entry.ContextMap()["request_id"], entry.Message)
+	}
+
+	// The unscoped service is left as it was
+	_, err = service.ResolveURL(url.ShortID, false)
+	require.NoError(t, err)
+	last := logs.All()[logs.Len()-1]
+	assert.NotContains(t, last.ContextMap(), "request_id")
+}
+
+func TestReservedAliases(t *testing.T) {
+	// Paths served by the API cannot be taken by links
+	for _, alias := range []string{"shorten", "quota", "metrics", "livez", "readyz", "report", "admin", "debug", "Quota", "ADMIN"} {
+		assert.False(t, validAlias(alias), alias)
+	}
+	assert.True(t, validAlias("quotas"))
+	assert.True(t, validAlias("reports"))
+}
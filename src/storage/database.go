@@ -4,12 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/yourusername/urlshortener/config"
+	"github.com/yourusername/urlshortener/src/tracing"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -51,6 +50,11 @@ func initSQLite(cfg config.SQLiteConfig) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// Trace queries as part of the request that made them
+	if err := db.Use(tracing.GormPlugin{System: "sqlite"}); err != nil {
+		return nil, err
+	}
+
 	// Set connection pool settings
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -66,36 +70,35 @@ func initSQLite(cfg config.SQLiteConfig) (*gorm.DB, error) {
 
 // initRedis initializes Redis connection
 func initRedis(cfg config.RedisConfig) (*redis.Client, error) {
-	// Get Redis URL from environment
-	redisURL := os.Getenv("REDIS_URL")
+	redisURL := cfg.URL
 	if redisURL == "" {
 		log.Printf("No REDIS_URL provided, skipping Redis initialization")
 		return nil, nil
 	}
 
-	// Log the raw Redis URL for debugging
-	log.Printf("Raw Redis URL from environment: %s", redisURL)
-
-	// Ensure URL has proper scheme
-	if !strings.HasPrefix(redisURL, "redis://") && !strings.HasPrefix(redisURL, "rediss://") {
-		redisURL = "redis://" + redisURL
-		log.Printf("Added redis:// scheme to URL: %s", redisURL)
-	}
-
 	// Parse Redis URL
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse Redis URL: %v", err)
 	}
 
+	// Settings given separately take precedence over the URL
+	if cfg.Password != "" {
+		opt.Password = cfg.Password
+	}
+	if cfg.DB != 0 {
+		opt.DB = cfg.DB
+	}
+
 	// Create Redis client
 	client := redis.NewClient(opt)
+	client.AddHook(tracing.RedisHook{})
 
 	// Test connection with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	log.Printf("Testing Redis connection to %s...", redisURL)
+	log.Printf("Testing Redis connection to %s...", config.RedactURL(redisURL))
 	if err := client.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
 	}
@@ -104,6 +107,21 @@ func initRedis(cfg config.RedisConfig) (*redis.Client, error) {
 	return client, nil
 }
 
+// PingSQLite checks that SQLite answers queries
+func (db *Database) PingSQLite(ctx context.Context) error {
+	var one int
+	return db.SQLite.WithContext(ctx).Raw("SELECT 1").Scan(&one).Error
+}
+
+// PingRedis checks that Redis answers commands. It fails if Redis is not
+// configured.
+func (db *Database) PingRedis(ctx context.Context) error {
+	if db.Redis == nil {
+		return fmt.Errorf("redis is not configured")
+	}
+	return db.Redis.Ping(ctx).Err()
+}
+
 // Close closes all database connections
 func (db *Database) Close() error {
 	// Close SQLite connection
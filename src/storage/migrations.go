@@ -8,7 +8,7 @@ import (
 // RunMigrations runs database migrations
 func RunMigrations(db *gorm.DB) error {
 	// Auto migrate the schema
-	if err := db.AutoMigrate(&models.URL{}, &models.Click{}); err != nil {
+	if err := db.AutoMigrate(&models.URL{}, &models.Click{}, &models.UnlockAttempt{}, &models.AuditEntry{}, &models.Report{}, &models.BannedKey{}); err != nil {
 		return err
 	}
 
@@ -0,0 +1,14 @@
+ALTER TABLE urls
+DROP COLUMN last_clicked_at;
+
+ALTER TABLE urls
+DROP COLUMN idle_expires_at;
+
+ALTER TABLE urls
+DROP COLUMN expire_after_inactive;
+
+ALTER TABLE urls
+DROP COLUMN click_count;
+
+ALTER TABLE urls
+DROP COLUMN max_clicks;
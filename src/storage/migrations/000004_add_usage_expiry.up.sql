@@ -0,0 +1,14 @@
+ALTER TABLE urls
+ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE urls
+ADD COLUMN click_count BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE urls
+ADD COLUMN expire_after_inactive INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE urls
+ADD COLUMN idle_expires_at TIMESTAMP;
+
+ALTER TABLE urls
+ADD COLUMN last_clicked_at TIMESTAMP;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_unlock_attempts_url_id;
+
+DROP TABLE IF EXISTS unlock_attempts;
+
+ALTER TABLE urls
+DROP COLUMN password_hash;
@@ -0,0 +1,12 @@
+ALTER TABLE urls
+ADD COLUMN password_hash VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS unlock_attempts (
+    id INTEGER PRIMARY KEY,
+    url_id INTEGER NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_unlock_attempts_url_id ON unlock_attempts(url_id);
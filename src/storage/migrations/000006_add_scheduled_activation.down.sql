@@ -0,0 +1,5 @@
+ALTER TABLE urls
+DROP COLUMN prelaunch_url;
+
+ALTER TABLE urls
+DROP COLUMN not_before;
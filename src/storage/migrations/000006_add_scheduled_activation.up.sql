@@ -0,0 +1,5 @@
+ALTER TABLE urls
+ADD COLUMN not_before TIMESTAMP;
+
+ALTER TABLE urls
+ADD COLUMN prelaunch_url TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE urls
+ADD COLUMN expired_redirect_url TEXT;
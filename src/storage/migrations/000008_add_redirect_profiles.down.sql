@@ -0,0 +1,11 @@
+ALTER TABLE urls
+DROP COLUMN redirect_no_index;
+
+ALTER TABLE urls
+DROP COLUMN redirect_referrer_policy;
+
+ALTER TABLE urls
+DROP COLUMN redirect_cache_max_age;
+
+ALTER TABLE urls
+DROP COLUMN redirect_status_code;
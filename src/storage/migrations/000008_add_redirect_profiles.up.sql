@@ -0,0 +1,11 @@
+ALTER TABLE urls
+ADD COLUMN redirect_status_code INTEGER;
+
+ALTER TABLE urls
+ADD COLUMN redirect_cache_max_age INTEGER;
+
+ALTER TABLE urls
+ADD COLUMN redirect_referrer_policy VARCHAR(64);
+
+ALTER TABLE urls
+ADD COLUMN redirect_no_index BOOLEAN;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_clicks_source;
+
+ALTER TABLE clicks
+DROP COLUMN source;
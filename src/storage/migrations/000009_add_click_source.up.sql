@@ -0,0 +1,4 @@
+ALTER TABLE clicks
+ADD COLUMN source VARCHAR(32);
+
+CREATE INDEX IF NOT EXISTS idx_clicks_source ON clicks(source);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_urls_owner_url_hash;
+
+ALTER TABLE urls
+DROP COLUMN url_hash;
+
+ALTER TABLE urls
+DROP COLUMN owner;
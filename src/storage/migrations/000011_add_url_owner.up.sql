@@ -0,0 +1,7 @@
+ALTER TABLE urls
+ADD COLUMN owner VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE urls
+ADD COLUMN url_hash VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_urls_owner_url_hash ON urls(owner, url_hash);
@@ -0,0 +1,5 @@
+ALTER TABLE urls
+DROP COLUMN risk_signals;
+
+ALTER TABLE urls
+DROP COLUMN risk_score;
@@ -0,0 +1,5 @@
+ALTER TABLE urls
+ADD COLUMN risk_score INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE urls
+ADD COLUMN risk_signals TEXT;
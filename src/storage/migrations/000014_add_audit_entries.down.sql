@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_entries_url_id;
+
+DROP TABLE IF EXISTS audit_entries;
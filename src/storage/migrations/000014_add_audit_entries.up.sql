@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id INTEGER PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    url_id INTEGER NOT NULL,
+    short_id VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    actor VARCHAR(64) NOT NULL,
+    detail TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_entries_url_id ON audit_entries(url_id);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS banned_keys;
+
+DROP INDEX IF EXISTS idx_reports_status;
+DROP INDEX IF EXISTS idx_reports_url_id;
+DROP TABLE IF EXISTS reports;
+
+ALTER TABLE urls
+DROP COLUMN disabled_reason;
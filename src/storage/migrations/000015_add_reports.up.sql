@@ -0,0 +1,27 @@
+ALTER TABLE urls
+ADD COLUMN disabled_reason VARCHAR(32);
+
+CREATE TABLE IF NOT EXISTS reports (
+    id INTEGER PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    url_id INTEGER NOT NULL,
+    short_id VARCHAR(255) NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    details TEXT,
+    reporter_ip VARCHAR(45) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    resolution VARCHAR(16),
+    note TEXT,
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_url_id ON reports(url_id);
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports(status);
+
+CREATE TABLE IF NOT EXISTS banned_keys (
+    id INTEGER PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    owner VARCHAR(64) NOT NULL UNIQUE,
+    reason TEXT
+);
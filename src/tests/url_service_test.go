@@ -176,24 +176,4 @@ func TestURLExpiration(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for expired URL, got nil")
 	}
-}
-
-// TestRateLimiting tests the rate limiting functionality
-func TestRateLimiting(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.Close()
-
-	service := services.NewURLService(db)
-
-	// Test rate limiting for same IP
-	ip := "192.168.1.1"
-	for i := 0; i < 100; i++ {
-		req := httptest.NewRequest("GET", "/", nil)
-		req.RemoteAddr = ip
-
-		allowed := service.CheckRateLimit(req)
-		if i >= 60 && allowed { // Assuming 60 requests per minute limit
-			t.Errorf("Rate limit not enforced at request %d", i+1)
-		}
-	}
-} 
\ No newline at end of file
+}
\ No newline at end of file
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// gormSpanKey is where the span of a statement is kept while it runs
+const gormSpanKey = "tracing:span"
+
+// GormPlugin traces gorm statements. Statements are children of the span in
+// the context given to db.WithContext.
+type GormPlugin struct {
+	// System names the database, e.g. sqlite
+	System string
+}
+
+// Name implements gorm.Plugin
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize implements gorm.Plugin by wrapping every operation in a span
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	register := func(operation string, before, after func(string, func(*gorm.DB)) error) error {
+		if err := before("tracing:before_"+operation, p.before(operation)); err != nil {
+			return err
+		}
+		return after("tracing:after_"+operation, p.after)
+	}
+	return errors.Join(
+		register("create", callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register),
+		register("query", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register),
+		register("update", callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register),
+		register("delete", callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register),
+		register("row", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register),
+		register("raw", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register),
+	)
+}
+
+// before starts the span of a statement
+func (p GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		_, span := Start(ctx, "gorm."+operation,
+			attribute.String("db.system", p.System),
+			attribute.String("db.operation", operation))
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+// after ends the span of a statement, recording the SQL without its values
+func (p GormPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	if !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		RecordError(span, db.Error)
+	}
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisHook traces Redis commands and pipelines. Commands are children of
+// the span in the context they are run with.
+type RedisHook struct{}
+
+var _ redis.Hook = RedisHook{}
+
+// BeforeProcess implements redis.Hook
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = Start(ctx, "redis."+cmd.Name(),
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", cmd.Name()))
+	return ctx, nil
+}
+
+// AfterProcess implements redis.Hook
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endRedisSpan(trace.SpanFromContext(ctx), cmd.Err())
+	return nil
+}
+
+// BeforeProcessPipeline implements redis.Hook
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.Name()
+	}
+	ctx, _ = Start(ctx, "redis.pipeline",
+		attribute.String("db.system", "redis"),
+		attribute.StringSlice("db.redis.commands", names))
+	return ctx, nil
+}
+
+// AfterProcessPipeline implements redis.Hook
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && !errors.Is(cmd.Err(), redis.Nil) {
+			err = cmd.Err()
+			break
+		}
+	}
+	endRedisSpan(trace.SpanFromContext(ctx), err)
+	return nil
+}
+
+// endRedisSpan ends a command span. A missing key is not an error.
+func endRedisSpan(span trace.Span, err error) {
+	if !errors.Is(err, redis.Nil) {
+		RecordError(span, err)
+	}
+	span.End()
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// recordSpans installs a tracer provider that keeps every finished span
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// spanNamed returns the first finished span called name
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %s", name)
+	return nil
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := recordSpans(t)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GormPlugin{System: "sqlite"}))
+
+	type item struct {
+		ID   uint
+		Name string
+	}
+	require.NoError(t, db.AutoMigrate(&item{}))
+
+	ctx, parent := Start(context.Background(), "request")
+	require.NoError(t, db.WithContext(ctx).Create(&item{Name: "a"}).Error)
+	err = db.WithContext(ctx).First(&item{}, "name = ?", "missing").Error
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	parent.End()
+
+	create := spanNamed(t, recorder, "gorm.create")
+	assert.Equal(t, parent.SpanContext().SpanID(), create.Parent().SpanID())
+	query := spanNamed(t, recorder, "gorm.query")
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Contains(t, attributes(query)["db.statement"], "WHERE name = ?")
+	assert.Equal(t, codes.Unset, query.Status().Code, "missing records are not errors")
+}
+
+func TestRedisHook(t *testing.T) {
+	recorder := recordSpans(t)
+	hook := RedisHook{}
+
+	for _, err := range []error{redis.Nil, assert.AnError} {
+		cmd := redis.NewStringCmd(context.Background(), "get", "key")
+		ctx, hookErr := hook.BeforeProcess(context.Background(), cmd)
+		require.NoError(t, hookErr)
+		cmd.SetErr(err)
+		require.NoError(t, hook.AfterProcess(ctx, cmd))
+	}
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "redis.get", spans[0].Name())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code, "missing keys are not errors")
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestPropagation(t *testing.T) {
+	recorder := recordSpans(t)
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(otelgin.Middleware("urlshortener"))
+	router.GET("/:shortID", func(c *gin.Context) {
+		_, span := Start(c.Request.Context(), "URLService.ResolveURL")
+		span.End()
+		c.Status(http.StatusFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// The request continues the caller's trace
+	request := spanNamed(t, recorder, "/:shortID")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", request.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", request.Parent().SpanID().String())
+	resolve := spanNamed(t, recorder, "URLService.ResolveURL")
+	assert.Equal(t, request.SpanContext().SpanID(), resolve.Parent().SpanID())
+}
+
+func TestSetupErrors(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.ErrorContains(t, err, `unknown trace exporter "jaeger"`)
+}
+
+// attributes returns the attributes of span as strings
+func attributes(span sdktrace.ReadOnlySpan) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range span.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	return attrs
+}